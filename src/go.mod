@@ -1,3 +1,5 @@
 module github.com/thomasteplick/mandelbrotset
 
 go 1.18
+
+require golang.org/x/image v0.18.0
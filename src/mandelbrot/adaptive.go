@@ -0,0 +1,137 @@
+// Adaptive refinement for adaptive=true.  The grid is split into blocks and the
+// border cells of each block are computed first.  A block whose border cells all
+// have the same iterations is filled with them without computing its interior,
+// otherwise it is split into four and each quarter is refined the same way.  The
+// uniform regions, the interior of the set and the wide escape bands, are
+// skipped and the compute goes to the detailed boundary.  The fill is exact
+// except where a detail is entirely enclosed by a uniform border, such as a
+// small island of the set inside an escape band.
+
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+const (
+	adaptiveBlock = 32 // side of the blocks refined concurrently
+	adaptiveMin   = 4  // side of the blocks computed in full instead of split
+)
+
+// adaptiveGrid computes the grid by refining the blocks concurrently.  The blocks
+// do not overlap, so each cell is written by one goroutine.
+func adaptiveGrid(p *Params) *Grid {
+	grid := Grid{p: p, its: make([]int, p.rows*p.columns), minits: p.iterations}
+	done := make([]bool, len(grid.its)) // the cell is computed or filled
+	it := fractals[p.fractal].iterator(p)
+
+	var wg sync.WaitGroup
+	counts := make(chan int)
+	for r0 := 0; r0 < p.rows; r0 += adaptiveBlock {
+		for c0 := 0; c0 < p.columns; c0 += adaptiveBlock {
+			r1, c1 := r0+adaptiveBlock-1, c0+adaptiveBlock-1
+			if r1 >= p.rows {
+				r1 = p.rows - 1
+			}
+			if c1 >= p.columns {
+				c1 = p.columns - 1
+			}
+			wg.Add(1)
+			go func(r0, c0, r1, c1 int) {
+				defer wg.Done()
+				// A panic fails the block instead of the server
+				defer func() {
+					if r := recover(); r != nil {
+						fmt.Printf("error: block at row %d column %d panicked: %v\n", r0, c0, r)
+						for row := r0; row <= r1; row++ {
+							for col := c0; col <= c1; col++ {
+								grid.its[row*p.columns+col] = failedIts
+							}
+						}
+						counts <- 0
+					}
+				}()
+				counts <- refineBlock(&grid, done, it, r0, c0, r1, c1)
+			}(r0, c0, r1, c1)
+		}
+	}
+	go func() {
+		wg.Wait()
+		close(counts)
+	}()
+	computed := 0
+	for n := range counts {
+		computed += n
+	}
+
+	for _, its := range grid.its {
+		if its == failedIts {
+			grid.failed = true
+			continue
+		}
+		if its < grid.minits {
+			grid.minits = its
+		}
+		if its > grid.maxits {
+			grid.maxits = its
+		}
+	}
+	fmt.Printf("Adaptive: computed %d of %d cells\n", computed, len(grid.its))
+	return &grid
+}
+
+// refineBlock fills the block from row r0 to r1 and column c0 to c1, inclusive, and
+// returns the number of cells it computed
+func refineBlock(grid *Grid, done []bool, it Iterator, r0, c0, r1, c1 int) int {
+	p := grid.p
+	computed := 0
+	cell := func(row, col int) int {
+		i := row*p.columns + col
+		if !done[i] {
+			grid.its[i], _, _ = determineSet(row, col, p, it)
+			done[i] = true
+			computed++
+		}
+		return grid.its[i]
+	}
+
+	// Small blocks are computed in full
+	if r1-r0 < adaptiveMin || c1-c0 < adaptiveMin {
+		for row := r0; row <= r1; row++ {
+			for col := c0; col <= c1; col++ {
+				cell(row, col)
+			}
+		}
+		return computed
+	}
+
+	first := cell(r0, c0)
+	uniform := true
+	for col := c0; col <= c1; col++ {
+		uniform = cell(r0, col) == first && uniform
+		uniform = cell(r1, col) == first && uniform
+	}
+	for row := r0 + 1; row < r1; row++ {
+		uniform = cell(row, c0) == first && uniform
+		uniform = cell(row, c1) == first && uniform
+	}
+	if uniform {
+		for row := r0 + 1; row < r1; row++ {
+			for col := c0 + 1; col < c1; col++ {
+				i := row*p.columns + col
+				grid.its[i] = first
+				done[i] = true
+			}
+		}
+		return computed
+	}
+
+	// The quarters share their inner borders, which are computed once
+	rm, cm := (r0+r1)/2, (c0+c1)/2
+	computed += refineBlock(grid, done, it, r0, c0, rm, cm)
+	computed += refineBlock(grid, done, it, r0, cm, rm, c1)
+	computed += refineBlock(grid, done, it, rm, c0, r1, cm)
+	computed += refineBlock(grid, done, it, rm, cm, r1, c1)
+	return computed
+}
@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+// The adaptive grid is the brute force grid except at a few cells of details
+// enclosed by uniform block borders, on the default window and boundary zooms
+func TestAdaptiveMatchesBruteForce(t *testing.T) {
+	const tolerance = 0.001 // largest fraction of the cells that may differ
+	for _, window := range []string{
+		"width=256&height=256&maxiter=500",
+		"width=300&height=200&maxiter=2000&xstart=-0.75&xend=-0.74&ystart=0.1&yend=0.11",
+		"width=200&height=200&maxiter=1000&xstart=-1.26&xend=-1.24&ystart=0.03&yend=0.05",
+		"width=150&height=150&maxiter=300&fractal=julia",
+	} {
+		full := computeGrid(testParams(t, window))
+		adaptive := computeGrid(testParams(t, window+"&adaptive=true"))
+		differ := 0
+		for i, its := range full.its {
+			if adaptive.its[i] != its {
+				differ++
+			}
+		}
+		if f := float64(differ) / float64(len(full.its)); f > tolerance {
+			t.Errorf("%s: %d of %d cells differ from brute force", window, differ, len(full.its))
+		}
+		if adaptive.minits != full.minits || adaptive.maxits != full.maxits {
+			t.Errorf("%s: iterations %d to %d adaptive, %d to %d brute force",
+				window, adaptive.minits, adaptive.maxits, full.minits, full.maxits)
+		}
+	}
+}
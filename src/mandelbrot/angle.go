@@ -0,0 +1,128 @@
+// External angles of points outside the set.  /mandelbrot/angle?x=...&y=...
+// iterates the point to a far escape radius and unwinds the arguments of its orbit
+// back to the point.  The Böttcher map takes the outside of the set to the outside
+// of the unit disk and multiplies the angle of an orbit point by the degree d at
+// every iteration, so the angle of the point is found by dividing the angle of the
+// last orbit point by d once per iteration, taking at each step the one of the d
+// candidates nearest the argument of the orbit point there.  The digits of those
+// choices are the base d expansion of the angle, binary for the quadratic sets.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/cmplx"
+	"net/http"
+	"strconv"
+)
+
+const (
+	patternAngle = "/mandelbrot/angle" // http handler pattern for external angles
+	angleRadius  = 1e10                // escape radius of the orbit, where its argument is that of the Böttcher map
+)
+
+// AngleJSON is the external angle of the point as sent to the client
+type AngleJSON struct {
+	X          float64  `json:"x"`
+	Y          float64  `json:"y"`
+	Fractal    string   `json:"fractal"`
+	Escaped    bool     `json:"escaped"`             // the orbit escaped within maxiter, the point has an angle
+	Iterations int      `json:"iterations"`          // iterations to reach the escape radius
+	Potential  *float64 `json:"potential,omitempty"` // Green's function, log|z_n| / d^n
+	Angle      *float64 `json:"angle,omitempty"`     // external angle in turns, from 0 to 1
+	Expansion  string   `json:"expansion,omitempty"` // base d digits of the angle, one per iteration, 0-9 then a-z
+}
+
+// handleAngle sends the external angle of the point (x,y) as JSON.  The angle is
+// defined for the Mandelbrot and multibrot sets iterated from 0 and for the Julia
+// sets, with the maxiter, c and power of the plot parameters.  A point of the set
+// has no angle, nor does one whose orbit needs more than maxiter iterations.
+func handleAngle(w http.ResponseWriter, r *http.Request) {
+	form, err := requestForm(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, &ParamError{Code: ErrBadRequest, Message: fmt.Sprintf("request parameters: %v", err)})
+		return
+	}
+	p, errs := parseParams(form)
+	var xy [2]float64
+	for i, name := range []string{"x", "y"} {
+		v, err := strconv.ParseFloat(form.Get(name), 64)
+		if err != nil {
+			errs.add(ErrNotNumber, name, "%s %q is not a number.", name, form.Get(name))
+		}
+		xy[i] = v
+	}
+	if (p.fractal != "mandelbrot" && p.fractal != "multibrot" && p.fractal != "julia") ||
+		(!p.julia && (p.z0 != 0 || p.zstartc)) {
+		errs.add(ErrNotApplicable, "fractal", "external angles do not apply to the %s fractal or a z0 of its own.", p.fractal)
+	} else if p.degree() > 36 {
+		errs.add(ErrOutOfRange, "power", "external angles need a power of at most 36 for the digits of the expansion.")
+	}
+	if len(errs) > 0 {
+		writeErrors(w, http.StatusBadRequest, errs)
+		return
+	}
+
+	res := AngleJSON{X: xy[0], Y: xy[1], Fractal: p.fractal}
+	externalAngle(complex(xy[0], xy[1]), p, &res)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		fmt.Printf("error: write angle: %v\n", err)
+	}
+}
+
+// externalAngle iterates the orbit of the point and sets the angle, potential and
+// expansion of the result if it escapes.  The orbit of a Mandelbrot point starts
+// at z1 = c, which is the point the angle is unwound to, and that of a Julia
+// point at the point itself.
+func externalAngle(point complex128, p *Params, res *AngleJSON) {
+	it := fractals[p.fractal].iterator(p)
+	d := p.degree()
+	v, c, first := complex128(0), point, 1
+	if p.julia {
+		v, c, first = point, p.c, 0
+	}
+	orbit := []complex128{v}
+	for n := 0; n < p.maxiter && cmplx.Abs(v) <= angleRadius; n++ {
+		v = it.Next(v, c)
+		orbit = append(orbit, v)
+	}
+	last := len(orbit) - 1
+	if cmplx.Abs(v) <= angleRadius || last <= first {
+		res.Iterations = p.maxiter
+		return
+	}
+	res.Escaped, res.Iterations = true, last-first
+
+	potential := math.Log(cmplx.Abs(v)) / math.Pow(float64(d), float64(last-first))
+	res.Potential = &potential
+
+	// turns is the argument of z in turns, from 0 to 1
+	turns := func(z complex128) float64 {
+		t := cmplx.Phase(z) / (2 * math.Pi)
+		if t < 0 {
+			t++
+		}
+		return t
+	}
+	angle := turns(v)
+	digits := make([]byte, last-first)
+	for k := last - 1; k >= first; k-- {
+		target := turns(orbit[k])
+		best, dist := 0, math.Inf(1)
+		for j := 0; j < d; j++ {
+			// distance around the circle from the candidate to the argument
+			delta := math.Abs((angle+float64(j))/float64(d) - target)
+			if delta = math.Min(delta, 1-delta); delta < dist {
+				best, dist = j, delta
+			}
+		}
+		angle = (angle + float64(best)) / float64(d)
+		digits[k-first] = strconv.FormatInt(int64(best), 36)[0]
+	}
+	res.Angle = &angle
+	res.Expansion = "0." + string(digits)
+}
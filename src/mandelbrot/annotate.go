@@ -0,0 +1,54 @@
+// Coordinate annotations of the PNG plot.  With annotate=true the window, the zoom
+// from the default window of the fractal and maxiter are stamped in a corner of
+// the image, so a shared image tells what region it shows.
+
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+const annotateMargin = 4 // pixels between the annotation text and the edges of its box
+
+var annotateBox = color.RGBA{0x00, 0x00, 0x00, 0xa0} // translucent black behind the text
+
+// annotation is the lines of the annotation text.  The coordinates have the fewest
+// digits that parse back to the window, so the region can be plotted again.
+func annotation(p *Params) []string {
+	def := fractals[p.fractal].endpoints
+	zoom := (def.xmax - def.xmin) / (p.ep.xmax - p.ep.xmin)
+	return []string{
+		fmt.Sprintf("x %g .. %g", p.ep.xmin, p.ep.xmax),
+		fmt.Sprintf("y %g .. %g", p.ep.ymin, p.ep.ymax),
+		fmt.Sprintf("zoom %.3gx  maxiter %d", zoom, p.maxiter),
+	}
+}
+
+// drawAnnotation draws the annotation in white on a box at the bottom left of the
+// plot image
+func drawAnnotation(img *image.RGBA, p *Params) {
+	face := basicfont.Face7x13
+	d := font.Drawer{Dst: img, Src: image.White, Face: face}
+	lines := annotation(p)
+	width := 0
+	for _, line := range lines {
+		if w := d.MeasureString(line).Ceil(); w > width {
+			width = w
+		}
+	}
+
+	h := img.Rect.Dy()
+	box := image.Rect(0, h-len(lines)*face.Height-2*annotateMargin, width+2*annotateMargin, h)
+	draw.Draw(img, box.Intersect(img.Rect), image.NewUniform(annotateBox), image.Point{}, draw.Over)
+	for i, line := range lines {
+		d.Dot = fixed.P(box.Min.X+annotateMargin, box.Min.Y+annotateMargin+i*face.Height+face.Ascent)
+		d.DrawString(line)
+	}
+}
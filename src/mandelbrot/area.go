@@ -0,0 +1,129 @@
+// Monte Carlo area estimates.  /mandelbrot/area?samples=...&seed=... iterates
+// random points of the window and estimates the area of the set in it from the
+// fraction of the points that do not escape within maxiter.  The points are drawn
+// from a generator of the request seeded with seed, so the same request gives the
+// same estimate.  Without a seed the time seeds it and the response tells which
+// seed was used, to repeat the estimate later.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"time"
+)
+
+const (
+	patternArea    = "/mandelbrot/area" // http handler pattern for area estimates
+	areaSamples    = 100000             // default number of random points
+	maxAreaSamples = 1 << 22            // most random points of an estimate
+	areaChunk      = 4096               // points iterated by each goroutine
+)
+
+// AreaJSON is the area estimate as sent to the client
+type AreaJSON struct {
+	Fractal string  `json:"fractal"`
+	Bounds  Bounds  `json:"bounds"` // window the points are drawn from
+	Seed    int64   `json:"seed"`   // seed of the points, to repeat the estimate
+	Samples int     `json:"samples"`
+	Members int     `json:"members"` // points that did not escape within maxiter
+	Area    float64 `json:"area"`    // of the set in the window
+	StdErr  float64 `json:"stderr"`  // standard error of the area
+}
+
+// handleArea sends the area estimate of the set in the window as JSON, with the
+// maxiter, fractal, its parameters and the seed of the plot parameters
+func handleArea(w http.ResponseWriter, r *http.Request) {
+	form, err := requestForm(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, &ParamError{Code: ErrBadRequest, Message: fmt.Sprintf("request parameters: %v", err)})
+		return
+	}
+	p, errs := parseParams(form)
+	samples := areaSamples
+	if s := form.Get("samples"); len(s) > 0 {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxAreaSamples {
+			errs.add(numberCode(err), "samples", "samples %q is not an integer from 1 to %d.", s, maxAreaSamples)
+		} else {
+			samples = n
+		}
+	}
+	// The seed of the plot parameters seeds the points when it is given
+	seed := int64(p.seed)
+	if len(form.Get("seed")) == 0 {
+		seed = time.Now().UnixNano()
+	}
+	if len(errs) > 0 {
+		writeErrors(w, http.StatusBadRequest, errs)
+		return
+	}
+
+	res, err := estimateArea(p, samples, seed)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		fmt.Printf("error: write area: %v\n", err)
+	}
+}
+
+// estimateArea iterates the random points of the window.  Each chunk of points
+// has a generator of its own, seeded in turn from the generator of the seed, so
+// the points do not depend on the order in which the chunks are iterated.  A
+// chunk that panics fails the estimate.
+func estimateArea(p *Params, samples int, seed int64) (AreaJSON, error) {
+	ep := p.ep
+	it := fractals[p.fractal].iterator(p)
+	rng := rand.New(rand.NewSource(seed))
+	members := make(chan int)
+	chunks := 0
+	for start := 0; start < samples; start += areaChunk {
+		n := areaChunk
+		if samples-start < n {
+			n = samples - start
+		}
+		go func(n int, seed int64) {
+			count := 0
+			defer func() {
+				if r := recover(); r != nil {
+					fmt.Printf("error: area chunk panicked: %v\n%s", r, debug.Stack())
+					count = -1
+				}
+				members <- count
+			}()
+			rng := rand.New(rand.NewSource(seed))
+			for i := 0; i < n; i++ {
+				z := complex(ep.xmin+rng.Float64()*(ep.xmax-ep.xmin), ep.ymin+rng.Float64()*(ep.ymax-ep.ymin))
+				if its, _, _ := iteratePoint(z, p, it); its == p.iterations {
+					count++
+				}
+			}
+		}(n, rng.Int63())
+		chunks++
+	}
+	res := AreaJSON{Fractal: p.fractal, Bounds: Bounds{ep.xmin, ep.xmax, ep.ymin, ep.ymax}, Seed: seed, Samples: samples}
+	failed := 0
+	for i := 0; i < chunks; i++ {
+		if n := <-members; n < 0 {
+			failed++
+		} else {
+			res.Members += n
+		}
+	}
+	if failed > 0 {
+		return res, fmt.Errorf("%d of %d chunks of the estimate panicked", failed, chunks)
+	}
+	window := (ep.xmax - ep.xmin) * (ep.ymax - ep.ymin)
+	f := float64(res.Members) / float64(samples)
+	res.Area = f * window
+	res.StdErr = window * math.Sqrt(f*(1-f)/float64(samples))
+	return res, nil
+}
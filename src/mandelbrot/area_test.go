@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"testing"
+)
+
+// The same seed gives the same estimate, another seed another one, and the
+// estimate of the window around the set is near its area
+func TestAreaSeed(t *testing.T) {
+	p := testParams(t, "maxiter=1000")
+	estimate := func(seed int64) AreaJSON {
+		res, err := estimateArea(p, 200000, seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	a, b := estimate(42), estimate(42)
+	if a != b {
+		t.Errorf("seed 42 estimated %v and %v", a.Area, b.Area)
+	}
+	if c := estimate(43); c.Members == a.Members {
+		t.Errorf("seeds 42 and 43 both found %d members", a.Members)
+	}
+	// The default window misses a sliver of the set on the negative real axis
+	const area = 1.5066
+	if math.Abs(a.Area-area) > 4*a.StdErr+0.01 {
+		t.Errorf("estimated area %v ± %v, the set has about %v", a.Area, a.StdErr, area)
+	}
+}
+
+// Without a seed the response has the time seed that repeats the estimate
+func TestAreaTimeSeed(t *testing.T) {
+	var first, again AreaJSON
+	w := serve(handleArea, patternArea+"?samples=5000")
+	if err := json.Unmarshal(w.Body.Bytes(), &first); err != nil {
+		t.Fatalf("status %d: %v", w.Code, err)
+	}
+	w = serve(handleArea, fmt.Sprintf("%s?samples=5000&seed=%d", patternArea, first.Seed))
+	if err := json.Unmarshal(w.Body.Bytes(), &again); err != nil {
+		t.Fatalf("status %d: %v", w.Code, err)
+	}
+	if first != again {
+		t.Errorf("seed %d estimated %+v, then %+v", first.Seed, first, again)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// The first and last axis labels of the page are the window bounds, and every
+// label is the coordinate cellPoint samples at its tick cell
+func TestAxisLabels(t *testing.T) {
+	for _, query := range []string{
+		"width=300&height=200&xstart=-1.5&xend=0.5&ystart=-0.6&yend=0.7",
+		"width=301&height=101&xstart=-0.8&xend=-0.7&ystart=0.1&yend=0.11&labelformat=sci",
+		"width=7&height=12",
+	} {
+		p := testParams(t, query)
+		w := serve(handlePlotting, pattern+"?"+query)
+		if w.Code != 200 {
+			t.Fatalf("%s: status %d", query, w.Code)
+		}
+		body := w.Body.String()
+		xlabels := labelValues(t, body, "xlabel")
+		ylabels := labelValues(t, body, "ylabel")
+		ep := p.ep
+		for _, axis := range []struct {
+			name      string
+			labels    []float64
+			lo, hi    float64
+			cells     int
+			point     func(cell int) float64
+			tickChild func(cell int) int
+		}{
+			{"x", xlabels, ep.xmin, ep.xmax, p.columns,
+				func(col int) float64 { return real(cellPoint(p.rows/2, col, p)) },
+				func(col int) int { return (p.rows-1)*p.columns + col + 1 }},
+			{"y", ylabels, ep.ymin, ep.ymax, p.rows,
+				func(row int) float64 { return imag(cellPoint(p.rows-1-row, p.columns/2, p)) },
+				func(row int) int { return (p.rows-1-row)*p.columns + 1 }},
+		} {
+			n := len(axis.labels)
+			// the labels are rounded to their format, which is finer than the step
+			step := (axis.hi - axis.lo) / float64(n-1)
+			round := step / 20
+			if math.Abs(axis.labels[0]-axis.lo) > round || math.Abs(axis.labels[n-1]-axis.hi) > round {
+				t.Errorf("%s: the %s labels run from %v to %v, the window from %v to %v",
+					query, axis.name, axis.labels[0], axis.labels[n-1], axis.lo, axis.hi)
+			}
+			half := (axis.hi - axis.lo) / float64(axis.cells-1) / 2
+			for i, label := range axis.labels {
+				cell := labelCell(i, axis.cells, n)
+				if v := axis.point(cell); math.Abs(v-label) > half+round {
+					t.Errorf("%s: %s label %d is %v, its cell %d is at %v", query, axis.name, i, label, cell, v)
+				}
+				tick := fmt.Sprintf(".grid div:nth-child(%d)", axis.tickChild(cell))
+				if i > 0 && i < n-1 && !strings.Contains(body, tick+",") && !strings.Contains(body, tick+" {") {
+					t.Errorf("%s: no tick at %s label %d, cell %d", query, axis.name, i, cell)
+				}
+			}
+		}
+	}
+}
+
+// labelValues are the numbers of the labels of the class on the page
+func labelValues(t *testing.T, body, class string) []float64 {
+	t.Helper()
+	var values []float64
+	for _, m := range regexp.MustCompile(`<div class="`+class+`">([^<]*)</div>`).FindAllStringSubmatch(body, -1) {
+		v, err := strconv.ParseFloat(m[1], 64)
+		if err != nil {
+			t.Fatalf("%s label %q: %v", class, m[1], err)
+		}
+		values = append(values, v)
+	}
+	if len(values) < 2 {
+		t.Fatalf("%d %s labels", len(values), class)
+	}
+	return values
+}
@@ -0,0 +1,146 @@
+// Named views.  A view is POSTed to /mandelbrot/bookmarks with a name and its
+// plot parameters, GET /mandelbrot/bookmarks lists the saved views and view=name
+// plots one, the request's own parameters taking precedence.  The views are kept
+// in a JSON file so they survive restarts.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+const patternBookmarks = "/mandelbrot/bookmarks" // http handler pattern for the named views
+
+// viewName is the form of a bookmark name
+var viewName = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// Bookmark is a named view as POSTed and listed
+type Bookmark struct {
+	Name string      `json:"name"`
+	View PlotRequest `json:"view"`
+}
+
+// Bookmarks are the saved views backed by the JSON file at path
+type Bookmarks struct {
+	mu    sync.Mutex
+	path  string
+	views map[string]PlotRequest
+}
+
+var bookmarks *Bookmarks
+
+// loadBookmarks reads the views saved in the file, there are none if it does not
+// exist yet
+func loadBookmarks(path string) (*Bookmarks, error) {
+	b := &Bookmarks{path: path, views: make(map[string]PlotRequest)}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return b, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &b.views); err != nil {
+		return nil, fmt.Errorf("bookmarks %s: %v", path, err)
+	}
+	return b, nil
+}
+
+// get returns the named view
+func (b *Bookmarks) get(name string) (PlotRequest, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	view, ok := b.views[name]
+	return view, ok
+}
+
+// list returns the views sorted by name
+func (b *Bookmarks) list() []Bookmark {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	list := make([]Bookmark, 0, len(b.views))
+	for name, view := range b.views {
+		list = append(list, Bookmark{name, view})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// put saves the view under the name, replacing a view of the same name, and
+// writes the file.  The file is replaced by a rename so it is never half written.
+func (b *Bookmarks) put(name string, view PlotRequest) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.views[name] = view
+	data, err := json.MarshalIndent(b.views, "", "\t")
+	if err != nil {
+		return err
+	}
+	tmp := b.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, b.path)
+}
+
+// viewForm chains the request form with the view it names, the request's values
+// first.  It returns false if the view is not saved.
+func viewForm(form Form) (Form, bool) {
+	name := form.Get("view")
+	if len(name) == 0 {
+		return form, true
+	}
+	view, ok := bookmarks.get(name)
+	if !ok {
+		return form, false
+	}
+	return forms{form, &view}, true
+}
+
+// handleBookmarks lists the saved views for GET and saves the view for POST
+func handleBookmarks(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(bookmarks.list()); err != nil {
+			fmt.Printf("error: write bookmarks: %v\n", err)
+		}
+	case http.MethodPost:
+		var bm Bookmark
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&bm); err != nil {
+			writeError(w, http.StatusBadRequest, &ParamError{Code: ErrBadRequest, Message: fmt.Sprintf("JSON body: %v", err)})
+			return
+		}
+		if !viewName.MatchString(bm.Name) {
+			writeError(w, http.StatusBadRequest, &ParamError{Code: ErrUnknownValue,
+				Message: fmt.Sprintf("name %q is not 1 to 64 letters, digits, _ or -", bm.Name), Param: "name"})
+			return
+		}
+		if _, errs := parseParams(&bm.View); len(errs) > 0 {
+			writeErrors(w, http.StatusBadRequest, errs)
+			return
+		}
+		if err := bookmarks.put(bm.Name, bm.View); err != nil {
+			fmt.Printf("error: save bookmarks: %v\n", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		fmt.Printf("Bookmark %s saved\n", bm.Name)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(bm); err != nil {
+			fmt.Printf("error: write bookmark: %v\n", err)
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeError(w, http.StatusMethodNotAllowed, &ParamError{Code: ErrMethod, Message: "bookmarks are listed with GET and saved with POST"})
+	}
+}
@@ -0,0 +1,79 @@
+// Time budget of a plot.  With maxtime the plot is rendered in the passes of the
+// progressive refinement until the next pass would overrun the budget, and the
+// best pass is returned at the size of the plot.
+
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// budgetGrid renders the passes of the plot from start until the time left is
+// not enough for the next pass, estimated from the cells and time of the last
+// one, and returns the best pass scaled up to the plot.  A cached grid or a plot
+// whose passes all fit is returned in full.
+func budgetGrid(p *Params, start time.Time, budget time.Duration) *Grid {
+	if grid, ok := grids.Get(*p); ok {
+		return grid
+	}
+	for f := refineStart; f > 1; f /= 2 {
+		pass := passParams(p, f)
+		begin := time.Now()
+		grid := computeGrid(pass)
+		next := passParams(p, f/2)
+		perCell := float64(time.Since(begin)) / float64(pass.rows*pass.columns)
+		estimate := time.Duration(perCell * float64(next.rows*next.columns))
+		if time.Since(start)+estimate > budget {
+			fmt.Printf("Time budget of %v: %d x %d pass of the %d x %d plot\n",
+				budget, pass.columns, pass.rows, p.columns/p.ssaa, p.rows/p.ssaa)
+			grid = upscaleGrid(grid, p)
+			if p.downscale > 1 {
+				grid = downscaleGrid(grid)
+			}
+			return grid
+		}
+	}
+	return renderGrid(p)
+}
+
+// upscaleGrid is the pass grid at the size of the plot, every cell repeated over
+// the cells of the plot it covers.  The overlay and inset grids are scaled up to
+// their sizes in the plot, and the slow cells and failed rows are kept.
+func upscaleGrid(grid *Grid, p *Params) *Grid {
+	up := Grid{p: p, its: make([]int, p.rows*p.columns), minits: grid.minits, maxits: grid.maxits, failed: grid.failed}
+	if grid.z != nil {
+		up.z = make([]complex128, len(up.its))
+		up.dz = make([]complex128, len(up.its))
+	}
+	var slow *SlowEscape
+	if grid.slow != nil {
+		s := *grid.slow
+		slow = &s
+		if s.cells != nil {
+			slow.cells = make([]bool, len(up.its))
+		}
+	}
+	for row := 0; row < p.rows; row++ {
+		src := row * grid.p.rows / p.rows * grid.p.columns
+		for col := 0; col < p.columns; col++ {
+			k := src + col*grid.p.columns/p.columns
+			i := row*p.columns + col
+			up.its[i] = grid.its[k]
+			if up.z != nil {
+				up.z[i], up.dz[i] = grid.z[k], grid.dz[k]
+			}
+			if slow != nil && slow.cells != nil {
+				slow.cells[i] = grid.slow.cells[k]
+			}
+		}
+	}
+	up.slow = slow
+	if grid.layer != nil {
+		up.layer = upscaleGrid(grid.layer, overlayParams(p))
+	}
+	if grid.inset != nil {
+		up.inset = upscaleGrid(grid.inset, insetParams(p))
+	}
+	return &up
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+// A pass scaled up to the plot keeps the overlay, the inset and the slow cells at
+// their sizes in the plot
+func TestUpscaleLayers(t *testing.T) {
+	for _, query := range []string{
+		"width=64&height=48&overlay=julia&inset=-0.8,-0.7,0,0.1",
+		"width=64&height=48&slowescape=flag&ssaa=2",
+	} {
+		p := testParams(t, query)
+		up := upscaleGrid(computeGrid(passParams(p, 4)), p)
+		full := computeGrid(p)
+		if len(up.its) != len(full.its) {
+			t.Fatalf("%s: %d cells scaled up, %d in the plot", query, len(up.its), len(full.its))
+		}
+		for _, layer := range []struct {
+			name     string
+			up, full *Grid
+		}{
+			{"overlay", up.layer, full.layer},
+			{"inset", up.inset, full.inset},
+		} {
+			if (layer.up == nil) != (layer.full == nil) {
+				t.Errorf("%s: the %s is %v scaled up, %v in the plot", query, layer.name, layer.up != nil, layer.full != nil)
+			} else if layer.up != nil && (layer.up.p.rows != layer.full.p.rows || layer.up.p.columns != layer.full.p.columns) {
+				t.Errorf("%s: the %s is %d x %d scaled up, %d x %d in the plot", query, layer.name,
+					layer.up.p.columns, layer.up.p.rows, layer.full.p.columns, layer.full.p.rows)
+			}
+		}
+		if (up.slow == nil) != (full.slow == nil) || up.slow != nil && len(up.slow.cells) != len(full.slow.cells) {
+			t.Errorf("%s: the slow cells are %v scaled up, %v in the plot", query, up.slow, full.slow)
+		}
+		var buf bytes.Buffer
+		if err := writePNG(&buf, up); err != nil {
+			t.Errorf("%s: %v", query, err)
+		}
+	}
+}
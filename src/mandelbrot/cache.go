@@ -0,0 +1,102 @@
+// Bounded least recently used cache for computed results, so repeated requests
+// for the same plot are served without recomputing the grid, and the coalescing
+// of identical computations that are in flight at the same time.
+
+package main
+
+import (
+	"container/list"
+	"sync"
+)
+
+// LRU is a concurrency safe cache holding at most size entries.  The least
+// recently used entry is evicted to make room for a new one.
+type LRU[K comparable, V any] struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List // entries from most to least recently used
+	items map[K]*list.Element
+}
+
+// entry is the list element value of an LRU item
+type entry[K comparable, V any] struct {
+	key   K
+	value V
+}
+
+// newLRU creates an LRU cache of the given size, a size of zero disables caching
+func newLRU[K comparable, V any](size int) *LRU[K, V] {
+	return &LRU[K, V]{size: size, order: list.New(), items: make(map[K]*list.Element)}
+}
+
+// Get returns the cached value for the key and marks it most recently used
+func (c *LRU[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[key]; ok {
+		c.order.MoveToFront(e)
+		return e.Value.(entry[K, V]).value, true
+	}
+	var zero V
+	return zero, false
+}
+
+// Put caches the value for the key, evicting the least recently used entry if full
+func (c *LRU[K, V]) Put(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.size <= 0 {
+		return
+	}
+	if e, ok := c.items[key]; ok {
+		e.Value = entry[K, V]{key, value}
+		c.order.MoveToFront(e)
+		return
+	}
+	c.items[key] = c.order.PushFront(entry[K, V]{key, value})
+	if c.order.Len() > c.size {
+		last := c.order.Back()
+		c.order.Remove(last)
+		delete(c.items, last.Value.(entry[K, V]).key)
+	}
+}
+
+// Flight runs one computation per key at a time:  callers that ask for a key
+// while it is being computed wait for that computation and share its result.
+type Flight[K comparable, V any] struct {
+	mu    sync.Mutex
+	calls map[K]*call[V]
+}
+
+// call is a computation in flight
+type call[V any] struct {
+	done  chan struct{} // closed when the value is set
+	value V
+	err   error
+}
+
+// newFlight creates an empty Flight
+func newFlight[K comparable, V any]() *Flight[K, V] {
+	return &Flight[K, V]{calls: make(map[K]*call[V])}
+}
+
+// Do returns the result of fn for the key, running fn unless a computation of
+// the key is already in flight.  shared is true if the result was another's.
+func (f *Flight[K, V]) Do(key K, fn func() (V, error)) (value V, err error, shared bool) {
+	f.mu.Lock()
+	if c, ok := f.calls[key]; ok {
+		f.mu.Unlock()
+		<-c.done
+		return c.value, c.err, true
+	}
+	c := &call[V]{done: make(chan struct{})}
+	f.calls[key] = c
+	f.mu.Unlock()
+
+	c.value, c.err = fn()
+	f.mu.Lock()
+	delete(f.calls, key)
+	f.mu.Unlock()
+	close(c.done)
+	return c.value, c.err, false
+}
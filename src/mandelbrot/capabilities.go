@@ -0,0 +1,106 @@
+// Server capabilities for clients building requests.  /mandelbrot/capabilities
+// lists the validation limits and the registered fractals, palettes, colorings
+// and output formats.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+)
+
+const patternCapabilities = "/mandelbrot/capabilities" // http handler pattern for the capabilities
+
+// colorings are the values of the coloring parameter
+var colorings = []string{"iterations", "smooth", "relief", "potential", "angle", "edge", "binary", "firstentry", "components", "lut"}
+
+// FractalJSON is a registered fractal as sent to the client
+type FractalJSON struct {
+	Name   string  `json:"name"`
+	Xmin   float64 `json:"xmin"` // default and widest window
+	Xmax   float64 `json:"xmax"`
+	Ymin   float64 `json:"ymin"`
+	Ymax   float64 `json:"ymax"`
+	Julia  bool    `json:"julia"`
+	Relief bool    `json:"relief"` // the relief coloring is available
+}
+
+// Capabilities are the server limits and registries
+type Capabilities struct {
+	Fractals      []FractalJSON `json:"fractals"`
+	Palettes      []string      `json:"palettes"`
+	Colorings     []string      `json:"colorings"`
+	Formats       []string      `json:"formats"`
+	Precisions    []string      `json:"precisions"`
+	AAPatterns    []string      `json:"aapatterns"`
+	Width         int           `json:"width"` // default plot size
+	Height        int           `json:"height"`
+	MaxSize       int           `json:"max_size"` // largest synchronous width or height
+	MaxHTMLCells  int           `json:"max_html_cells"`
+	MaxPoster     int           `json:"max_poster"` // largest width or height of a job
+	Maxiter       int           `json:"maxiter"`    // default maximum iterations
+	MaxIterations int           `json:"max_iterations"`
+	Radius        float64       `json:"radius"` // default escape radius
+	MaxRadius     float64       `json:"max_radius"`
+	MaxSSAA       int           `json:"max_ssaa"`
+	MaxDPR        float64       `json:"max_dpr"`
+	MaxContrast   float64       `json:"max_contrast"`
+	MaxGamma      float64       `json:"max_gamma"`
+	MaxSheet      int           `json:"max_sheet"` // most windows on a contact sheet
+	TileSize      int           `json:"tile_size"`
+	MaxTileZoom   int           `json:"max_tile_zoom"`
+	MaxStress     int           `json:"max_stress,omitempty"` // only when the stress test is served
+}
+
+// handleCapabilities sends the capabilities as JSON
+func handleCapabilities(w http.ResponseWriter, r *http.Request) {
+	c := Capabilities{
+		Colorings:     colorings,
+		Precisions:    []string{"float64", "float32"},
+		AAPatterns:    []string{"grid", "jitter", "stratified"},
+		Width:         columns,
+		Height:        rows,
+		MaxSize:       maxSize,
+		MaxHTMLCells:  *htmlCells,
+		MaxPoster:     maxPoster,
+		Maxiter:       maxIterations,
+		MaxIterations: iterLimit,
+		Radius:        radius,
+		MaxRadius:     maxRadius,
+		MaxSSAA:       maxSSAA,
+		MaxDPR:        maxDPR,
+		MaxContrast:   maxContrast,
+		MaxGamma:      maxGamma,
+		MaxSheet:      maxSheet,
+		TileSize:      tileSize,
+		MaxTileZoom:   maxTileZoom,
+	}
+	if *stress {
+		c.MaxStress = maxStress
+	}
+
+	for name, f := range fractals {
+		fj := FractalJSON{Name: name, Xmin: f.endpoints.xmin, Xmax: f.endpoints.xmax,
+			Ymin: f.endpoints.ymin, Ymax: f.endpoints.ymax, Julia: f.julia}
+		p, _ := parseParams(url.Values{"fractal": {name}})
+		_, fj.Relief = f.iterator(p).(Differentiator)
+		c.Fractals = append(c.Fractals, fj)
+	}
+	sort.Slice(c.Fractals, func(i, j int) bool { return c.Fractals[i].Name < c.Fractals[j].Name })
+	for name := range palettes {
+		c.Palettes = append(c.Palettes, name)
+	}
+	sort.Strings(c.Palettes)
+	for name := range encoders {
+		c.Formats = append(c.Formats, name)
+	}
+	sort.Strings(c.Formats)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(c); err != nil {
+		fmt.Printf("error: write capabilities: %v\n", err)
+	}
+}
@@ -0,0 +1,66 @@
+// Command line rendering.  With -out the program renders one plot to the file
+// and exits without starting the server, so plots can be scripted.  The window,
+// size and maxiter come from the flags and any other plot parameter from -query,
+// and the format is that of the file extension, png, json, npy, tiff, ...
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// renderFile renders the plot of the command line flags to the file
+func renderFile(path string) error {
+	form, err := url.ParseQuery(*cliQuery)
+	if err != nil {
+		return fmt.Errorf("-query: %v", err)
+	}
+	// The window is given whole or not at all, as for the plot parameters
+	window := map[string]string{"xstart": *cliXmin, "xend": *cliXmax, "ystart": *cliYmin, "yend": *cliYmax}
+	given := 0
+	for name, v := range window {
+		if len(v) > 0 {
+			form.Set(name, v)
+			given++
+		}
+	}
+	if given != 0 && given != len(window) {
+		return fmt.Errorf("-xmin, -xmax, -ymin and -ymax are given together")
+	}
+	for name, n := range map[string]int{"width": *cliWidth, "height": *cliHeight} {
+		if n > 0 {
+			form.Set(name, strconv.Itoa(n))
+		}
+	}
+
+	format := strings.TrimPrefix(filepath.Ext(path), ".")
+	enc, ok := encoders[format]
+	if !ok {
+		return fmt.Errorf("unknown format %q of %s", format, path)
+	}
+	p, errs := parseParams(form)
+	if len(errs) > 0 {
+		return errs[0]
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(f)
+	if err := enc.write(bw, renderGrid(p)); err != nil {
+		f.Close()
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
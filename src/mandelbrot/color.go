@@ -0,0 +1,669 @@
+// Coloring of the grid cells.  The color stage is shared by the HTML and image
+// output formats.
+
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"math/cmplx"
+	"strconv"
+	"strings"
+)
+
+const highlightDim = .25 // brightness of the cells outside the highlighted band
+
+var highlightColor = color.RGBA{0xff, 0x00, 0x00, 0xff} // default highlight, red
+
+var maskColor = color.RGBA{0xff, 0xff, 0xff, 0xff} // default mask background, white
+
+var axesColor = color.RGBA{0xff, 0x00, 0x00, 0xff} // default color of the axes, red
+
+var failColor = color.RGBA{0xff, 0x00, 0xff, 0xff} // cells whose worker panicked, magenta
+
+// binaryColors are the exterior and member colors of the binary coloring
+var binaryColors = [2]color.RGBA{{0xff, 0xff, 0xff, 0xff}, {0x00, 0x00, 0x00, 0xff}}
+
+// transparent is the color of the members of the set with transparentset=true
+var transparent = color.RGBA{}
+
+const edgeThreshold = .1 // Sobel gradient of the normalized iterations that is an edge
+
+var (
+	// shades of gray from white (not in the set) to black (in the set)
+	grays = []color.RGBA{
+		{0xff, 0xff, 0xff, 0xff},
+		{0xcc, 0xcc, 0xcc, 0xff},
+		{0x88, 0x88, 0x88, 0xff},
+		{0x44, 0x44, 0x44, 0xff},
+		{0x00, 0x00, 0x00, 0xff},
+	}
+
+	// palettes keyed by the palette parameter, from the fastest escaping cells
+	// to the members of the set
+	palettes = map[string][]color.RGBA{
+		"gray": grays,
+	}
+)
+
+// plotImage is the colored grid with one pixel per cell.  A supersampled grid
+// is box filtered down by averaging each ssaa x ssaa block of cells.
+func plotImage(grid *Grid) *image.RGBA {
+	colors := colorize(grid)
+	n := grid.p.ssaa
+	columns := grid.p.columns / n
+	img := image.NewRGBA(image.Rect(0, 0, columns, grid.p.rows/n))
+	for y := 0; y < img.Rect.Dy(); y++ {
+		for x := 0; x < columns; x++ {
+			var r, g, b, a int
+			for sy := 0; sy < n; sy++ {
+				row := colors[(y*n+sy)*grid.p.columns+x*n:]
+				for _, c := range row[:n] {
+					r += int(c.R)
+					g += int(c.G)
+					b += int(c.B)
+					a += int(c.A)
+				}
+			}
+			s := n * n
+			img.SetRGBA(x, y, color.RGBA{uint8((r + s/2) / s), uint8((g + s/2) / s), uint8((b + s/2) / s), uint8((a + s/2) / s)})
+		}
+	}
+	if grid.layer != nil {
+		blendPixels(img, plotImage(grid.layer), grid.p.blend)
+	}
+	if grid.p.circle || grid.p.vignette > 0 {
+		maskPixels(img, grid.p)
+	}
+	if grid.p.axes {
+		axesPixels(img, grid.p)
+	}
+	if grid.inset != nil {
+		insetPixels(img, grid)
+	}
+	return img
+}
+
+// blendPixels mixes the pixels of the top image into the image with the opacity
+func blendPixels(img, top *image.RGBA, opacity float64) {
+	for i, c := range top.Pix {
+		img.Pix[i] = uint8(float64(img.Pix[i])*(1-opacity) + float64(c)*opacity + .5)
+	}
+}
+
+// maskPixels paints the pixels outside the circle inscribed in the image the mask
+// color and fades the others toward it by the vignette strength times the square
+// of their distance from the center, relative to the distance of the corners
+func maskPixels(img *image.RGBA, p *Params) {
+	w, h := float64(img.Rect.Dx()), float64(img.Rect.Dy())
+	radius := math.Min(w, h) / 2
+	corner := math.Hypot(w/2, h/2)
+	mc := p.mcolor
+	for y := 0; y < img.Rect.Dy(); y++ {
+		for x := 0; x < img.Rect.Dx(); x++ {
+			d := math.Hypot(float64(x)+.5-w/2, float64(y)+.5-h/2)
+			if p.circle && d > radius {
+				img.SetRGBA(x, y, mc)
+				continue
+			}
+			f := p.vignette * (d / corner) * (d / corner)
+			if f == 0 {
+				continue
+			}
+			c := img.RGBAAt(x, y)
+			mix := func(a, b uint8) uint8 {
+				return uint8(float64(a)*(1-f) + float64(b)*f + .5)
+			}
+			img.SetRGBA(x, y, color.RGBA{mix(c.R, mc.R), mix(c.G, mc.G), mix(c.B, mc.B), mix(c.A, mc.A)})
+		}
+	}
+}
+
+// axesPixels draws the real and imaginary axes over the pixels where they cross
+// the window.  A pixel is on an axis if the axis passes between its center and
+// the center of the pixel to its right or below, so a rotated axis stays a
+// connected line one pixel wide.
+func axesPixels(img *image.RGBA, p *Params) {
+	n := p.ssaa
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	columns, rows := p.fullSize()
+	// center of the pixel, the middle of its cells in the full image of a tile
+	center := func(x, y int) complex128 {
+		fx := cellFraction(x*n+p.offx, columns) + float64(n-1)/2/float64(columns-1)
+		fy := cellFraction(y*n+p.offy, rows) + float64(n-1)/2/float64(rows-1)
+		return planePoint(fx, fy, p)
+	}
+	crosses := func(a, b float64) bool { return (a <= 0) != (b <= 0) }
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			z := center(x, y)
+			for _, d := range [2][2]int{{1, 0}, {0, 1}} {
+				if x+d[0] >= w || y+d[1] >= h {
+					continue
+				}
+				o := center(x+d[0], y+d[1])
+				if crosses(real(z), real(o)) || crosses(imag(z), imag(o)) {
+					img.SetRGBA(x, y, p.acolor)
+				}
+			}
+		}
+	}
+}
+
+// colorize colors every cell of the grid for the plot's coloring.  Colorings
+// that are normalized over the whole grid color all the cells at once.
+func colorize(grid *Grid) []color.RGBA {
+	colors := make([]color.RGBA, len(grid.its))
+	// The failed cells are colored as escaping at once and then painted the fail
+	// color, so the colorings never see the sentinel
+	failed := grid.its
+	if grid.failed {
+		g := *grid
+		g.its = make([]int, len(failed))
+		for i, its := range failed {
+			if its != failedIts {
+				g.its[i] = its
+			}
+		}
+		grid = &g
+	}
+	switch grid.p.coloring {
+	case "potential":
+		potentialColors(grid, colors)
+	case "edge":
+		edgeColors(grid, colors)
+	case "firstentry":
+		firstEntryColors(grid, colors)
+	case "components":
+		componentColors(grid, colors)
+	case "lut":
+		lutColors(grid, colors)
+	default:
+		for i := range colors {
+			colors[i] = cellColor(grid, i)
+		}
+	}
+	if grid.p.bgtint > 0 {
+		tintColors(grid, colors)
+	}
+	if grid.p.highlight {
+		highlightColors(grid, colors)
+	}
+	if grid.p.clearset {
+		for i, its := range grid.its {
+			if its == grid.p.iterations {
+				colors[i] = transparent
+			}
+		}
+	}
+	if grid.slow != nil {
+		for i, slow := range grid.slow.cells {
+			if slow {
+				colors[i] = slowColor
+			}
+		}
+	}
+	if grid.failed {
+		for i, its := range failed {
+			if its == failedIts {
+				colors[i] = failColor
+			}
+		}
+	}
+	return colors
+}
+
+// tintColors blends the colors of the escaped cells with a tint that shifts from
+// blue to violet along the diagonal of the window, from the top left corner to the
+// bottom right, by the background strength.  The set members keep their color.
+func tintColors(grid *Grid, colors []color.RGBA) {
+	p := grid.p
+	ep := &p.ep
+	f := p.bgtint
+	mix := func(a, b uint8) uint8 {
+		return uint8(float64(a)*(1-f) + float64(b)*f + .5)
+	}
+	for i, its := range grid.its {
+		if its == p.iterations {
+			continue
+		}
+		z := cellPoint(i/p.columns, i%p.columns, p)
+		t := ((real(z)-ep.xmin)/(ep.xmax-ep.xmin) + (ep.ymax-imag(z))/(ep.ymax-ep.ymin)) / 2
+		tint := hsv(.55+.3*math.Max(0, math.Min(1, t)), .5, 1)
+		c := colors[i]
+		colors[i] = color.RGBA{mix(c.R, tint.R), mix(c.G, tint.G), mix(c.B, tint.B), c.A}
+	}
+}
+
+// highlightColors paints the cells with iterations in the highlighted band and
+// dims the others
+func highlightColors(grid *Grid, colors []color.RGBA) {
+	p := grid.p
+	dim := func(x uint8) uint8 {
+		return uint8(float64(x)*highlightDim + .5)
+	}
+	for i, its := range grid.its {
+		if its >= p.hlo && its <= p.hhi {
+			colors[i] = p.hcolor
+		} else {
+			c := colors[i]
+			colors[i] = color.RGBA{dim(c.R), dim(c.G), dim(c.B), c.A}
+		}
+	}
+}
+
+// parseHexColor parses the color as rrggbb hexadecimal with an optional leading #,
+// returning def if it is empty
+func parseHexColor(value string, def color.RGBA) (color.RGBA, error) {
+	if len(value) == 0 {
+		return def, nil
+	}
+	hex := strings.TrimPrefix(value, "#")
+	if len(hex) != 6 {
+		return def, fmt.Errorf("color %q is not rrggbb hexadecimal", value)
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return def, fmt.Errorf("color %q is not rrggbb hexadecimal", value)
+	}
+	return color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 0xff}, nil
+}
+
+// cellColor is the color of cell i of the grid for the per-cell colorings
+func cellColor(grid *Grid, i int) color.RGBA {
+	switch grid.p.coloring {
+	case "relief":
+		return reliefColor(grid, i)
+	case "smooth":
+		return smoothColor(grid, i)
+	case "angle":
+		return angleColor(grid, i)
+	case "binary":
+		return binaryColors[membership(grid, i)]
+	case "iterations":
+		if grid.p.channel != "" {
+			return channelColor(grid, i)
+		}
+		fallthrough
+	default:
+		return palettes[grid.p.palette][colorIndex(grid.its[i], grid)]
+	}
+}
+
+// channelColor is the normalized iterations of the cell as the intensity of the
+// chosen channel from 0 to 255, the other channels zero.  The members of the set
+// are 255.
+func channelColor(grid *Grid, i int) color.RGBA {
+	v := 1.0
+	if its := grid.its[i]; its != grid.p.iterations {
+		v = 0
+		if lo, hi := grid.colorRange(); hi > lo {
+			v = math.Max(0, math.Min(1, grid.p.adjust(grid.normalize(float64(its)))))
+		}
+	}
+	c := color.RGBA{A: 0xff}
+	switch x := uint8(v*255 + .5); grid.p.channel {
+	case "r":
+		c.R = x
+	case "g":
+		c.G = x
+	default:
+		c.B = x
+	}
+	return c
+}
+
+// colorIndex maps the cell iterations to one of the colors:  higher iterations
+// are dark gray to black, lower iterations are white to lighter shades of gray.
+// Black denotes members of the set.  With colormod the iterations modulo colormod
+// are spread over the palette instead, repeating it in bands.
+func colorIndex(its int, grid *Grid) int {
+	// scale for iterations to color
+	n := len(palettes[grid.p.palette])
+	var v float64
+	if m := grid.p.colormod; m > 0 {
+		if its == grid.p.iterations {
+			return n - 1
+		}
+		v = grid.p.adjust(float64(its%m) / float64(m-1))
+	} else {
+		lo, hi := grid.colorRange()
+		if hi == lo {
+			return 0
+		}
+		v = grid.p.adjust(grid.normalize(float64(its)))
+	}
+	k := int(v*float64(n-1) + .5)
+	// A fixed color range may not cover all the cells
+	if k < 0 {
+		return 0
+	}
+	if k > n-1 {
+		return n - 1
+	}
+	return k
+}
+
+// adjust applies the gamma, contrast and brightness to the normalized value v of
+// a cell, from 0 for the first palette color to 1 for the last.  The gamma
+// correction raises v to 1/gamma, then the contrast scales v about the middle of
+// the palette and the brightness shifts it.
+func (p *Params) adjust(v float64) float64 {
+	if p.gamma != 1 {
+		v = math.Pow(math.Max(0, math.Min(1, v)), 1/p.gamma)
+	}
+	if p.contrast == 1 && p.brightness == 0 {
+		return v
+	}
+	return math.Max(0, math.Min(1, (v-.5)*p.contrast+.5+p.brightness))
+}
+
+// colorRange is the range of iterations spread over the palette, the range of
+// the grid unless colormin or colormax fix it
+func (grid *Grid) colorRange() (int, int) {
+	lo, hi := grid.minits, grid.maxits
+	if grid.p.colormin >= 0 {
+		lo = grid.p.colormin
+	}
+	if grid.p.colormax >= 0 {
+		hi = grid.p.colormax
+	}
+	return lo, hi
+}
+
+// normalize is the position of the iterations in the color range, from 0 at the
+// start to 1 at the end.  The log color scale spreads log(1 + its) over the range
+// instead, expanding the detail of the low iterations.
+func (grid *Grid) normalize(its float64) float64 {
+	lo, hi := grid.colorRange()
+	if grid.p.logscale {
+		l := math.Log1p(float64(lo))
+		return (math.Log1p(math.Max(its, 0)) - l) / (math.Log1p(float64(hi)) - l)
+	}
+	return (its - float64(lo)) / float64(hi-lo)
+}
+
+// denormalize is the iterations at position v of the color range, the inverse of normalize
+func (grid *Grid) denormalize(v float64) float64 {
+	lo, hi := grid.colorRange()
+	if grid.p.logscale {
+		l := math.Log1p(float64(lo))
+		return math.Expm1(l + v*(math.Log1p(float64(hi))-l))
+	}
+	return float64(lo) + v*float64(hi-lo)
+}
+
+// reliefColor shades the cell as an embossed surface lit from the light direction.
+// The surface normal is the direction of z/dz at escape, the members of the set
+// are black.
+func reliefColor(grid *Grid, i int) color.RGBA {
+	if grid.its[i] == grid.p.iterations || grid.dz[i] == 0 {
+		return grays[len(grays)-1]
+	}
+	u := grid.z[i] / grid.dz[i]
+	u /= complex(cmplx.Abs(u), 0)
+
+	// Lambertian reflection of the light, the height keeps the cells facing away
+	// from the light from going completely dark
+	light := grid.p.light
+	h := grid.p.height
+	shade := (real(u)*real(light) + imag(u)*imag(light) + h) / (1 + h)
+	shade = math.Max(0, math.Min(1, shade))
+
+	g := uint8(shade*255 + .5)
+	return color.RGBA{g, g, g, 0xff}
+}
+
+// smoothIterations is the fractional escape iteration of the cell,
+// n + 1 - log_d(ln|z| / ln R), which is continuous across the iteration bands
+func smoothIterations(grid *Grid, i int) float64 {
+	return smoothEscape(grid.its[i], grid.z[i], grid.p)
+}
+
+// smoothEscape is the fractional escape iteration of an orbit that escaped after
+// its iterations with the final z
+func smoothEscape(its int, z complex128, p *Params) float64 {
+	lnz := math.Log(cmplx.Abs(z))
+	return float64(its) + 1 - math.Log(lnz/math.Log(p.radius))/math.Log(float64(p.degree()))
+}
+
+// smoothColor blends the palette colors by the fractional escape iteration of the cell
+func smoothColor(grid *Grid, i int) color.RGBA {
+	palette := palettes[grid.p.palette]
+	lo, hi := grid.colorRange()
+	if grid.its[i] == grid.p.iterations || hi == lo {
+		return palette[len(palette)-1]
+	}
+	return gradient(palette, grid.p.adjust(grid.normalize(smoothIterations(grid, i))))
+}
+
+// gradient interpolates the colors linearly at v from 0 (first color) to 1 (last color)
+func gradient(palette []color.RGBA, v float64) color.RGBA {
+	// NaN, as from the log of the zero z of a failed cell, is the first color
+	if math.IsNaN(v) {
+		v = 0
+	}
+	v = math.Max(0, math.Min(1, v)) * float64(len(palette)-1)
+	k := int(v)
+	if k >= len(palette)-1 {
+		return palette[len(palette)-1]
+	}
+	f := v - float64(k)
+	a, b := palette[k], palette[k+1]
+	mix := func(x, y uint8) uint8 {
+		return uint8(float64(x)*(1-f) + float64(y)*f + .5)
+	}
+	return color.RGBA{mix(a.R, b.R), mix(a.G, b.G), mix(a.B, b.B), mix(a.A, b.A)}
+}
+
+// angleColor maps the argument of z at escape around the hue wheel, so the cells
+// show the stripes and swirls of the orbit phase.  The brightness falls with the
+// iterations toward the set, whose members are black.
+func angleColor(grid *Grid, i int) color.RGBA {
+	if grid.its[i] == grid.p.iterations {
+		return color.RGBA{0x00, 0x00, 0x00, 0xff}
+	}
+	hue := (cmplx.Phase(grid.z[i]) + math.Pi) / (2 * math.Pi)
+	v := 0.0
+	if lo, hi := grid.colorRange(); hi > lo {
+		v = grid.normalize(float64(grid.its[i]))
+	}
+	return hsv(hue, 1, 1-.75*grid.p.adjust(v))
+}
+
+// hsv converts the hue, saturation and value, all from 0 to 1, to a color
+func hsv(h, s, v float64) color.RGBA {
+	h = math.Mod(h, 1) * 6
+	k := math.Floor(h)
+	f := h - k
+	p, q, t := v*(1-s), v*(1-s*f), v*(1-s*(1-f))
+	var r, g, b float64
+	switch int(k) {
+	case 0:
+		r, g, b = v, t, p
+	case 1:
+		r, g, b = q, v, p
+	case 2:
+		r, g, b = p, v, t
+	case 3:
+		r, g, b = p, q, v
+	case 4:
+		r, g, b = t, p, v
+	default:
+		r, g, b = v, p, q
+	}
+	c := func(x float64) uint8 {
+		return uint8(math.Max(0, math.Min(1, x))*255 + .5)
+	}
+	return color.RGBA{c(r), c(g), c(b), 0xff}
+}
+
+// edgeColors draws the boundary as line art:  the cells where the Sobel gradient
+// of the normalized iterations is steep get the last palette color, all the others
+// the first.  The cells outside the grid repeat the edge cells.
+func edgeColors(grid *Grid, colors []color.RGBA) {
+	palette := palettes[grid.p.palette]
+	rows, columns := grid.p.rows, grid.p.columns
+	lo, hi := grid.colorRange()
+	scale := 0.0
+	if hi > lo {
+		scale = 1 / float64(hi-lo)
+	}
+	v := func(row, col int) float64 {
+		if row < 0 {
+			row = 0
+		} else if row >= rows {
+			row = rows - 1
+		}
+		if col < 0 {
+			col = 0
+		} else if col >= columns {
+			col = columns - 1
+		}
+		return float64(grid.its[row*columns+col]-lo) * scale
+	}
+
+	for row := 0; row < rows; row++ {
+		for col := 0; col < columns; col++ {
+			gx := v(row-1, col+1) + 2*v(row, col+1) + v(row+1, col+1) -
+				v(row-1, col-1) - 2*v(row, col-1) - v(row+1, col-1)
+			gy := v(row+1, col-1) + 2*v(row+1, col) + v(row+1, col+1) -
+				v(row-1, col-1) - 2*v(row-1, col) - v(row-1, col+1)
+			if math.Hypot(gx, gy) > edgeThreshold {
+				colors[row*columns+col] = palette[len(palette)-1]
+			} else {
+				colors[row*columns+col] = palette[0]
+			}
+		}
+	}
+}
+
+// membership is 1 if the cell is a member of the set, it did not escape within
+// the iteration cap, otherwise 0
+func membership(grid *Grid, i int) int {
+	if grid.its[i] == grid.p.iterations {
+		return 1
+	}
+	return 0
+}
+
+// firstEntryColors spreads the iteration at which the orbit first entered the trap
+// disk over the palette, from the earliest entry to the latest in the grid.  Most
+// orbits enter early, so the logarithm of the entry is spread.  The contours follow
+// the preimages of the disk.  The cells whose orbit never entered
+// the disk get the last palette color.
+func firstEntryColors(grid *Grid, colors []color.RGBA) {
+	palette := palettes[grid.p.palette]
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, dz := range grid.dz {
+		if n := real(dz); n >= 0 {
+			lo = math.Min(lo, n)
+			hi = math.Max(hi, n)
+		}
+	}
+
+	for i, dz := range grid.dz {
+		n := real(dz)
+		switch {
+		case n < 0:
+			colors[i] = palette[len(palette)-1]
+		case hi == lo:
+			colors[i] = palette[0]
+		default:
+			colors[i] = gradient(palette, grid.p.adjust(math.Log1p(n-lo)/math.Log1p(hi-lo)))
+		}
+	}
+}
+
+// lutColors colors the cells with the lookup table of the request indexed by
+// their iterations, without normalization.  The members of the set get the color
+// of maxiter, as do the cells that escape after maxiter with a larger radius.
+func lutColors(grid *Grid, colors []color.RGBA) {
+	var lut []color.RGBA
+	for _, hex := range strings.Split(grid.p.lut, ",") {
+		c, _ := parseHexColor(hex, color.RGBA{}) // validated by parseParams
+		lut = append(lut, c)
+	}
+	for i, its := range grid.its {
+		if its > grid.p.maxiter {
+			its = grid.p.maxiter
+		}
+		colors[i] = lut[its]
+	}
+}
+
+// componentColors labels the connected components of the members of the set, the
+// cells that share an edge, and gives each component its own hue.  The hues are
+// spaced by the golden ratio around the wheel so that the components labeled one
+// after another differ.  The escaped cells are colored by their iterations.
+func componentColors(grid *Grid, colors []color.RGBA) {
+	p := grid.p
+	for i := range colors {
+		colors[i] = palettes[p.palette][colorIndex(grid.its[i], grid)]
+	}
+	label := make([]int, len(grid.its)) // component of the cell from 1, 0 for not yet labeled
+	var stack []int
+	n := 0
+	for start, its := range grid.its {
+		if its != p.iterations || label[start] != 0 {
+			continue
+		}
+		n++
+		c := hsv(math.Mod(float64(n)*.618033988749895, 1), .6, .95)
+		visit := func(k int) {
+			if grid.its[k] == p.iterations && label[k] == 0 {
+				label[k] = n
+				stack = append(stack, k)
+			}
+		}
+		visit(start)
+		for len(stack) > 0 {
+			i := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			colors[i] = c
+			row, col := i/p.columns, i%p.columns
+			if row > 0 {
+				visit(i - p.columns)
+			}
+			if row < p.rows-1 {
+				visit(i + p.columns)
+			}
+			if col > 0 {
+				visit(i - 1)
+			}
+			if col < p.columns-1 {
+				visit(i + 1)
+			}
+		}
+	}
+}
+
+// potentialColors colors the exterior by the electrostatic potential of the set,
+// log|z| / d^n at escape.  The potential falls off exponentially toward the set,
+// so the logarithm of its logarithm is spread over the palette, shading the
+// equipotential curves smoothly from the outside in to the boundary.
+func potentialColors(grid *Grid, colors []color.RGBA) {
+	palette := palettes[grid.p.palette]
+	logd := math.Log(float64(grid.p.degree()))
+	phi := make([]float64, len(grid.its)) // -log of the potential
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for i, its := range grid.its {
+		if its == grid.p.iterations {
+			continue
+		}
+		// -log(log|z| / d^(n+1)), n+1 iterations were done at escape
+		phi[i] = float64(its+1)*logd - math.Log(math.Log(cmplx.Abs(grid.z[i])))
+		lo = math.Min(lo, phi[i])
+		hi = math.Max(hi, phi[i])
+	}
+
+	for i, its := range grid.its {
+		if its == grid.p.iterations || hi == lo {
+			colors[i] = palette[len(palette)-1]
+			continue
+		}
+		colors[i] = gradient(palette, grid.p.adjust(math.Log1p(phi[i]-lo)/math.Log1p(hi-lo)))
+	}
+}
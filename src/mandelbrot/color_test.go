@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"testing"
+)
+
+// Near the cusp of the cardioid the cells escape after more than 32767
+// iterations, which the grid, the JSON and the colors keep in order
+func TestHighIterations(t *testing.T) {
+	const query = "maxiter=40000&width=21&height=1&xstart=0.250000001&xend=0.250000021&ystart=-1e-9&yend=1e-9"
+	grid := computeGrid(testParams(t, query))
+	high := 0
+	for i, its := range grid.its {
+		if its < 0 || its > 40000 {
+			t.Fatalf("cell %d took %d iterations", i, its)
+		}
+		if i > 0 && its > grid.its[i-1] {
+			t.Errorf("cell %d took %d iterations, more than the %d of the cell nearer the cusp", i, its, grid.its[i-1])
+		}
+		if its > 32767 && its < 40000 {
+			high++
+		}
+	}
+	if high == 0 {
+		t.Fatalf("no cell escaped after 32767 iterations: %v", grid.its)
+	}
+	if grid.maxits != 40000 || grid.minits != grid.its[len(grid.its)-1] {
+		t.Errorf("iterations %d to %d, the cells %v", grid.minits, grid.maxits, grid.its)
+	}
+
+	var plot PlotJSON
+	w := serve(handlePlotting, pattern+"?format=json&"+query)
+	if err := json.Unmarshal(w.Body.Bytes(), &plot); err != nil {
+		t.Fatalf("status %d: %v", w.Code, err)
+	}
+	for i, its := range plot.Iterations {
+		if its != grid.its[i] {
+			t.Fatalf("cell %d has %d iterations in the JSON, %d in the grid", i, its, grid.its[i])
+		}
+	}
+
+	// The palette index and the channel intensity grow with the iterations, and
+	// the members of the set get the last palette color
+	for _, coloring := range []string{"", "&channel=b"} {
+		g := computeGrid(testParams(t, query+coloring))
+		cells := make([]int, len(g.its))
+		for i := range cells {
+			cells[i] = i
+		}
+		sort.Slice(cells, func(a, b int) bool { return g.its[cells[a]] < g.its[cells[b]] })
+		n := len(palettes[g.p.palette])
+		for k := 1; k < len(cells); k++ {
+			i, prev := cells[k], cells[k-1]
+			if coloring == "" {
+				ci, cp := colorIndex(g.its[i], g), colorIndex(g.its[prev], g)
+				if ci < cp || ci < 0 || ci > n-1 {
+					t.Errorf("%d iterations have color %d, %d iterations color %d", g.its[i], ci, g.its[prev], cp)
+				}
+				if g.its[i] == 40000 && ci != n-1 {
+					t.Errorf("a member of the set has color %d of %d", ci, n)
+				}
+			} else if c, cp := channelColor(g, i), channelColor(g, prev); c.B < cp.B {
+				t.Errorf("%d iterations have intensity %d, %d iterations %d", g.its[i], c.B, g.its[prev], cp.B)
+			}
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+// Concurrent plots of different windows each get the grid of their own window.
+// Run with -race to check the handlers share the caches and template safely.
+func TestConcurrentPlots(t *testing.T) {
+	const n = 32
+	plots := make([]PlotJSON, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			xstart := -1.5 + float64(i)/50
+			target := fmt.Sprintf("%s?width=40&height=30&xstart=%v&xend=%v&ystart=-0.5&yend=0.5",
+				pattern, xstart, xstart+1)
+			// Every fourth request is a page and a PNG of the same window, to share the
+			// cached grid between the encoders
+			if i%4 == 0 {
+				for _, format := range []string{"html", "png"} {
+					w := serve(handlePlotting, target+"&format="+format)
+					if w.Code != http.StatusOK || w.Body.Len() == 0 {
+						t.Errorf("plot %d %s: status %d, %d bytes", i, format, w.Code, w.Body.Len())
+					}
+				}
+			}
+			w := serve(handlePlotting, target+"&format=json")
+			if w.Code != http.StatusOK {
+				t.Errorf("plot %d: status %d: %s", i, w.Code, w.Body)
+				return
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &plots[i]); err != nil {
+				t.Errorf("plot %d: %v", i, err)
+				return
+			}
+			if plot := plots[i]; plot.Xmin != xstart || plot.Xmax != xstart+1 || plot.Rows != 30 || plot.Columns != 40 ||
+				len(plot.Iterations) != 30*40 {
+				t.Errorf("plot %d: %d x %d cells from %v to %v, requested 40 x 30 from %v to %v",
+					i, plot.Columns, plot.Rows, plot.Xmin, plot.Xmax, xstart, xstart+1)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if t.Failed() {
+		return
+	}
+
+	seen := make(map[string]int)
+	for i, plot := range plots {
+		key := fmt.Sprint(plot.Iterations)
+		if j, ok := seen[key]; ok {
+			t.Errorf("plots %d and %d of different windows have the same iterations", j, i)
+		}
+		seen[key] = i
+	}
+}
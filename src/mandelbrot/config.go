@@ -0,0 +1,118 @@
+// Startup configuration.  The -config file sets the default plot size and
+// maxiter, the default windows of the fractals, additional palettes and the
+// request limits without recompiling, and the flags given on the command line
+// take precedence over it.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"image/color"
+	"os"
+)
+
+// maxIterLimit is the largest iterlimit.  The iteration cap, a few iterations
+// above maxiter for a large escape radius, then fits the int32 counts of the npy
+// and binary formats and the int of 32-bit platforms.
+const maxIterLimit = 1 << 30
+
+// Config is the JSON form of the -config file.  Missing or zero values keep the
+// built-in defaults.
+type Config struct {
+	Rows      int                   `json:"rows"`
+	Columns   int                   `json:"columns"`
+	Maxiter   int                   `json:"maxiter"`
+	Iterlimit int                   `json:"iterlimit"` // largest maxiter accepted from a request
+	Maxsize   int                   `json:"maxsize"`   // largest width or height of a synchronous plot
+	Windows   map[string][4]float64 `json:"windows"`   // fractal name to [xmin, xmax, ymin, ymax]
+	Palettes  map[string][]string   `json:"palettes"`  // palette name to rrggbb colors, escaping cells first
+}
+
+// configure applies the config file, if any, and then the flags that were set
+// on the command line to the defaults and limits, and checks they are consistent
+func configure(path string) error {
+	if len(path) > 0 {
+		if err := loadConfig(path); err != nil {
+			return fmt.Errorf("config %s: %v", path, err)
+		}
+	}
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "rows":
+			rows = *rowsFlag
+		case "columns":
+			columns = *colsFlag
+		case "maxiter":
+			maxIterations = *iterFlag
+		case "iterlimit":
+			iterLimit = *limitFlag
+		case "maxsize":
+			maxSize = *sizeFlag
+		}
+	})
+
+	if maxSize < 1 {
+		return fmt.Errorf("maxsize %d is not positive", maxSize)
+	}
+	if rows < 1 || rows > maxSize || columns < 1 || columns > maxSize {
+		return fmt.Errorf("default size %d x %d is not from 1 to maxsize %d", columns, rows, maxSize)
+	}
+	if iterLimit < 1 || iterLimit > maxIterLimit {
+		return fmt.Errorf("iterlimit %d is not from 1 to %d", iterLimit, maxIterLimit)
+	}
+	if maxIterations < 1 || maxIterations > iterLimit {
+		return fmt.Errorf("maxiter %d is not from 1 to iterlimit %d", maxIterations, iterLimit)
+	}
+	return nil
+}
+
+// loadConfig reads the config file and replaces the defaults it sets
+func loadConfig(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var c Config
+	if err := json.Unmarshal(data, &c); err != nil {
+		return err
+	}
+
+	set := func(v *int, n int) {
+		if n != 0 {
+			*v = n
+		}
+	}
+	set(&rows, c.Rows)
+	set(&columns, c.Columns)
+	set(&maxIterations, c.Maxiter)
+	set(&iterLimit, c.Iterlimit)
+	set(&maxSize, c.Maxsize)
+
+	for name, w := range c.Windows {
+		f, ok := fractals[name]
+		if !ok {
+			return fmt.Errorf("window of unknown fractal %q", name)
+		}
+		if w[0] >= w[1] || w[2] >= w[3] {
+			return fmt.Errorf("window %v of %s is not [xmin, xmax, ymin, ymax]", w, name)
+		}
+		f.endpoints = Endpoints{w[0], w[1], w[2], w[3]}
+		fractals[name] = f
+	}
+
+	for name, hex := range c.Palettes {
+		if len(hex) < 2 {
+			return fmt.Errorf("palette %s has fewer than 2 colors", name)
+		}
+		palette := make([]color.RGBA, len(hex))
+		for i, h := range hex {
+			if palette[i], err = parseHexColor(h, color.RGBA{}); err != nil || len(h) == 0 {
+				return fmt.Errorf("palette %s: %q is not an rrggbb color", name, h)
+			}
+		}
+		palettes[name] = palette
+	}
+	return nil
+}
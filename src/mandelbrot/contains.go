@@ -0,0 +1,89 @@
+// Set membership of a single point.  /mandelbrot/contains?x=...&y=... iterates
+// the point with the plot parameters and tells whether it is in the set.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+const patternContains = "/mandelbrot/contains" // http handler pattern for set membership
+
+// ContainsJSON is the membership of the point as sent to the client
+type ContainsJSON struct {
+	X          float64  `json:"x"`
+	Y          float64  `json:"y"`
+	Fractal    string   `json:"fractal"`
+	InSet      bool     `json:"in_set"`     // the orbit did not escape within the iteration cap
+	Iterations int      `json:"iterations"` // iterations before escaping, the cap if in the set
+	Maxiter    int      `json:"maxiter"`
+	Shortcut   bool     `json:"shortcut"`         // the main cardioid or period 2 bulb contains the point
+	Smooth     *float64 `json:"smooth,omitempty"` // fractional escape iteration with smooth=true, none in the set
+}
+
+// handleContains iterates the point (x,y) of the fractal and sends its membership
+// as JSON.  Points of the Mandelbrot set's main cardioid and period 2 bulb are
+// known members and are not iterated, unless optimize=false.  With smooth=true
+// an escaping point also gets the fractional iteration of the smooth coloring.
+func handleContains(w http.ResponseWriter, r *http.Request) {
+	form, err := requestForm(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, &ParamError{Code: ErrBadRequest, Message: fmt.Sprintf("request parameters: %v", err)})
+		return
+	}
+	p, errs := parseParams(form)
+	var xy [2]float64
+	for i, name := range []string{"x", "y"} {
+		v, err := strconv.ParseFloat(form.Get(name), 64)
+		if err != nil {
+			errs.add(ErrNotNumber, name, "%s %q is not a number.", name, form.Get(name))
+		}
+		xy[i] = v
+	}
+	var smooth bool
+	switch s := form.Get("smooth"); s {
+	case "", "false":
+	case "true":
+		smooth = true
+	default:
+		errs.add(ErrUnknownValue, "smooth", "smooth %q is not true or false.", s)
+	}
+	if len(errs) > 0 {
+		writeErrors(w, http.StatusBadRequest, errs)
+		return
+	}
+
+	c := complex(xy[0], xy[1])
+	res := ContainsJSON{X: xy[0], Y: xy[1], Fractal: p.fractal, Maxiter: p.maxiter}
+	if p.bulbs && inMainBulbs(c) {
+		res.Iterations, res.Shortcut = p.iterations, true
+	} else {
+		var z complex128
+		res.Iterations, z, _ = iteratePoint(c, p, fractals[p.fractal].iterator(p))
+		if smooth && res.Iterations < p.iterations {
+			s := smoothEscape(res.Iterations, z, p)
+			res.Smooth = &s
+		}
+	}
+	res.InSet = res.Iterations == p.iterations
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		fmt.Printf("error: write contains: %v\n", err)
+	}
+}
+
+// inMainBulbs is true if c is in the main cardioid or the period 2 bulb of the
+// Mandelbrot set, which hold most of its area and never escape
+func inMainBulbs(c complex128) bool {
+	x, y := real(c), imag(c)
+	y2 := y * y
+	q := (x-.25)*(x-.25) + y2
+	if q*(q+x-.25) <= .25*y2 {
+		return true
+	}
+	return (x+1)*(x+1)+y2 <= 1.0/16
+}
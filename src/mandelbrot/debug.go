@@ -0,0 +1,173 @@
+// Debug output of the resolved plot parameters.  With debug=true (or an
+// X-Debug header) the plot endpoint returns the parameters it resolved after the
+// defaults, validation, zooming and clamping as JSON instead of the plot.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/cmplx"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// ParamsJSON is the JSON form of the resolved plot parameters
+type ParamsJSON struct {
+	Requested      map[string]string `json:"requested"` // the parameters as received
+	Errors         ParamErrors       `json:"errors"`    // invalid parameters replaced by their defaults
+	Format         string            `json:"format"`    // negotiated content type
+	Xmin           float64           `json:"xmin"`
+	Xmax           float64           `json:"xmax"`
+	Ymin           float64           `json:"ymin"`
+	Ymax           float64           `json:"ymax"`
+	Rows           int               `json:"rows"`
+	Columns        int               `json:"columns"`
+	Fractal        string            `json:"fractal"`
+	Julia          bool              `json:"julia"`
+	Creal          float64           `json:"creal"`
+	Cimag          float64           `json:"cimag"`
+	Z0real         float64           `json:"z0real"`
+	Z0imag         float64           `json:"z0imag"`
+	Zstart         string            `json:"zstart"`
+	Power          int               `json:"power"`
+	Precision      string            `json:"precision"`
+	Fixedpoint     bool              `json:"fixedpoint"`
+	Adaptive       bool              `json:"adaptive"`
+	Workaxis       string            `json:"workaxis"`
+	Slowescape     string            `json:"slowescape"`
+	Bulbshortcut   bool              `json:"bulbshortcut"`
+	Pinned         bool              `json:"pinned"`
+	Pinx           float64           `json:"pinx"`
+	Piny           float64           `json:"piny"`
+	Coloring       string            `json:"coloring"`
+	Lightangle     float64           `json:"lightangle"`
+	Lightheight    float64           `json:"lightheight"`
+	Maxiter        int               `json:"maxiter"`
+	Radius         float64           `json:"radius"`
+	Iterations     int               `json:"iterations"` // iteration cap for maxiter and the radius
+	Interiorcutoff float64           `json:"interiorcutoff"`
+	Diffmaxiter    int               `json:"diffmaxiter"`
+	Palette        string            `json:"palette"`
+	Rotate         float64           `json:"rotate"` // degrees
+	Projection     string            `json:"projection"`
+	SSAA           int               `json:"ssaa"`
+	AAPattern      string            `json:"aapattern"`
+	Seed           int               `json:"seed"`
+	Downscale      int               `json:"downscale"`
+	Pool           string            `json:"pool"`
+	DPR            float64           `json:"dpr"`
+	Colormin       int               `json:"colormin"` // -1 follows the grid
+	Colormax       int               `json:"colormax"`
+	Brightness     float64           `json:"brightness"`
+	Contrast       float64           `json:"contrast"`
+	Gamma          float64           `json:"gamma"`
+	Colorscale     string            `json:"colorscale"`
+	Colormod       int               `json:"colormod"`
+	Jsonvalue      string            `json:"jsonvalue"`
+	Includez       bool              `json:"includez"`
+	Highlight      bool              `json:"highlight"`
+	Highlightlo    int               `json:"highlightlo"`
+	Highlighthi    int               `json:"highlighthi"`
+	Highlightcolor string            `json:"highlightcolor"`
+}
+
+// debugRequested is true if the client asked for the resolved parameters
+func debugRequested(r *http.Request, form Form) bool {
+	return form.Get("debug") == "true" || len(r.Header.Get("X-Debug")) > 0
+}
+
+// writeDebug sends the resolved parameters and the parameters they were resolved from
+func writeDebug(w io.Writer, form Form, p *Params, enc Encoder, errs ParamErrors) error {
+	requested := make(map[string]string)
+	t := reflect.TypeOf(PlotRequest{})
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if v := form.Get(name); len(v) > 0 {
+			requested[name] = v
+		}
+	}
+
+	if errs == nil {
+		errs = ParamErrors{}
+	}
+	colorscale := "linear"
+	if p.logscale {
+		colorscale = "log"
+	}
+	zstart := "zero"
+	if p.zstartc {
+		zstart = "c"
+	}
+	pool := "mean"
+	if p.poolmax {
+		pool = "max"
+	}
+	precision := "float64"
+	if p.single {
+		precision = "float32"
+	}
+	je := json.NewEncoder(w)
+	je.SetIndent("", "  ")
+	return je.Encode(ParamsJSON{
+		Requested:      requested,
+		Errors:         errs,
+		Format:         enc.contentType,
+		Xmin:           p.ep.xmin,
+		Xmax:           p.ep.xmax,
+		Ymin:           p.ep.ymin,
+		Ymax:           p.ep.ymax,
+		Rows:           p.rows,
+		Columns:        p.columns,
+		Fractal:        p.fractal,
+		Julia:          p.julia,
+		Creal:          real(p.c),
+		Cimag:          imag(p.c),
+		Z0real:         real(p.z0),
+		Z0imag:         imag(p.z0),
+		Zstart:         zstart,
+		Power:          p.power,
+		Precision:      precision,
+		Fixedpoint:     p.fixed,
+		Adaptive:       p.adaptive,
+		Workaxis:       p.workaxis,
+		Slowescape:     p.slowesc,
+		Bulbshortcut:   p.bulbs,
+		Pinned:         p.pinned,
+		Pinx:           real(p.pin),
+		Piny:           imag(p.pin),
+		Coloring:       p.coloring,
+		Lightangle:     cmplx.Phase(p.light) * 180 / math.Pi,
+		Lightheight:    p.height,
+		Maxiter:        p.maxiter,
+		Radius:         p.radius,
+		Iterations:     p.iterations,
+		Interiorcutoff: p.cutoff,
+		Diffmaxiter:    p.diffmax,
+		Palette:        p.palette,
+		Rotate:         cmplx.Phase(p.rotation) * 180 / math.Pi,
+		Projection:     p.projection,
+		SSAA:           p.ssaa,
+		AAPattern:      p.aapattern,
+		Seed:           p.seed,
+		Downscale:      p.downscale,
+		Pool:           pool,
+		DPR:            p.dpr,
+		Colormin:       p.colormin,
+		Colormax:       p.colormax,
+		Brightness:     p.brightness,
+		Contrast:       p.contrast,
+		Gamma:          p.gamma,
+		Colorscale:     colorscale,
+		Colormod:       p.colormod,
+		Jsonvalue:      p.jsonvalue,
+		Includez:       p.includez,
+		Highlight:      p.highlight,
+		Highlightlo:    p.hlo,
+		Highlighthi:    p.hhi,
+		Highlightcolor: fmt.Sprintf("#%02x%02x%02x", p.hcolor.R, p.hcolor.G, p.hcolor.B),
+	})
+}
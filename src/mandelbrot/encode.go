@@ -0,0 +1,552 @@
+// Output formats for the computed grid.  The same grid is presented as the HTML
+// page, a PNG image, JSON data, the points of the set or the iterations as a
+// NumPy array, a TIFF or a compact binary layout depending on what the client
+// asks for.
+
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/tiff"
+)
+
+// Encoder writes the grid to the client in one output format
+type Encoder struct {
+	contentType string                                     // MIME type of the output
+	write       func(io.Writer, *Grid) error               // presentation of the grid
+	timed       func(*Timing) func(io.Writer, *Grid) error // write with the timing field, nil if the format has none
+}
+
+// PlotJSON is the grid as sent to JSON clients
+type PlotJSON struct {
+	Xmin       float64       `json:"xmin"`
+	Xmax       float64       `json:"xmax"`
+	Ymin       float64       `json:"ymin"`
+	Ymax       float64       `json:"ymax"`
+	Rows       int           `json:"rows"`
+	Columns    int           `json:"columns"`
+	Minits     int           `json:"minits"`
+	Maxits     int           `json:"maxits"`
+	Iterations []int         `json:"iterations,omitempty"` // row-major cell iterations with jsonvalue=raw
+	Values     []float64     `json:"values,omitempty"`     // row-major normalized or smooth cell values otherwise
+	Z          []*[2]float64 `json:"z,omitempty"`          // row-major final z, real and imaginary, with includez=true, null in the set
+	SetBounds  *Bounds       `json:"setbounds"`            // bounding box of the members of the set, null if none
+	Timing     *Timing       `json:"timing,omitempty"`     // compute time with timing=true
+}
+
+// Bounds is a box of the complex plane
+type Bounds struct {
+	Xmin float64 `json:"xmin"`
+	Xmax float64 `json:"xmax"`
+	Ymin float64 `json:"ymin"`
+	Ymax float64 `json:"ymax"`
+}
+
+const cellSize = 2 // CSS pixels per cell of the HTML plot
+
+// encoders keyed by the format parameter
+var encoders = map[string]Encoder{
+	"html":     {"text/html; charset=utf-8", writeHTML, nil},
+	"png":      {"image/png", writePNG, nil},
+	"json":     {"application/json", writeJSON, writeTimedJSON},
+	"npy":      {"application/octet-stream", writeNPY, nil},
+	"tiff":     {"image/tiff", writeTIFF, nil},
+	"binary":   {"application/octet-stream", writeBinary, nil},
+	"points":   {"application/json", writePoints, nil},
+	"ascii":    {"text/plain; charset=utf-8", writeASCII, nil},
+	"json+png": {"application/json", writePNGJSON, writeTimedPNGJSON},
+}
+
+// browser is true for the HTML page, the output of the browser flow
+func (enc Encoder) browser() bool {
+	return enc.contentType == encoders["html"].contentType
+}
+
+// negotiate selects the encoder from the format parameter or, if it is absent,
+// from the Accept header.  HTML is used when nothing else is acceptable.
+// It returns false if the format parameter names an unknown format.
+func negotiate(r *http.Request, form Form) (Encoder, bool) {
+	if format := form.Get("format"); len(format) > 0 {
+		enc, ok := encoders[format]
+		return enc, ok
+	}
+
+	type mediaRange struct {
+		mime string
+		q    float64
+	}
+	var accept []mediaRange
+	for _, field := range strings.Split(r.Header.Get("Accept"), ",") {
+		parts := strings.Split(field, ";")
+		mr := mediaRange{mime: strings.TrimSpace(parts[0]), q: 1}
+		for _, param := range parts[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					mr.q = q
+				}
+			}
+		}
+		if mr.q > 0 {
+			accept = append(accept, mr)
+		}
+	}
+	// Highest quality first, header order breaks ties
+	sort.SliceStable(accept, func(i, j int) bool { return accept[i].q > accept[j].q })
+
+	for _, mr := range accept {
+		switch mr.mime {
+		case "text/html", "*/*":
+			return encoders["html"], true
+		case "image/png", "image/*":
+			return encoders["png"], true
+		case "application/json":
+			return encoders["json"], true
+		case "image/tiff":
+			return encoders["tiff"], true
+		}
+	}
+	return encoders["html"], true
+}
+
+// writeHTML plots the grid as the HTML page using the template
+func writeHTML(w io.Writer, grid *Grid) error {
+	return plotHTML(w, grid, nil)
+}
+
+// plotHTML plots the grid as the HTML page and lists the errors of the invalid
+// parameters that were replaced by their defaults
+func plotHTML(w io.Writer, grid *Grid, errs ParamErrors) error {
+	ep := grid.p.ep
+	plot := PlotT{
+		Fractal:  grid.p.fractal,
+		Coloring: grid.p.coloring,
+		Xstart:   strconv.FormatFloat(ep.xmin, 'g', -1, 64),
+		Xend:     strconv.FormatFloat(ep.xmax, 'g', -1, 64),
+		Ystart:   strconv.FormatFloat(ep.ymin, 'g', -1, 64),
+		Yend:     strconv.FormatFloat(ep.ymax, 'g', -1, 64),
+	}
+	if grid.p.pinned {
+		plot.Pinx = strconv.FormatFloat(real(grid.p.pin), 'g', -1, 64)
+		plot.Piny = strconv.FormatFloat(imag(grid.p.pin), 'g', -1, 64)
+	}
+
+	img := plotImage(grid)
+	dpr := grid.p.dpr
+	plot.Layout = gridLayout(img.Rect.Dy(), img.Rect.Dx(), dpr)
+	plot.Width = int(math.Round(float64(img.Rect.Dx()*grid.p.downscale) / dpr))
+	plot.Height = int(math.Round(float64(img.Rect.Dy()*grid.p.downscale) / dpr))
+	plot.DPR = strconv.FormatFloat(dpr, 'g', -1, 64)
+	plot.Grid = make([]template.CSS, 0, img.Rect.Dx()*img.Rect.Dy())
+	plot.Xlabel = make([]string, xlabels)
+	plot.Ylabel = make([]string, ylabels)
+
+	// Set the background color for all the cells in the grid based on cell iteration.
+	// The colors are generated here, so they are trusted CSS for the template.  A
+	// plot has few colors, so the style of each is formatted once.
+	styles := make(map[[3]uint8]template.CSS)
+	for i := 0; i < len(img.Pix); i += 4 {
+		rgb := [3]uint8{img.Pix[i], img.Pix[i+1], img.Pix[i+2]}
+		style, ok := styles[rgb]
+		if !ok {
+			style = template.CSS(fmt.Sprintf("background-color: #%02x%02x%02x", rgb[0], rgb[1], rgb[2]))
+			styles[rgb] = style
+		}
+		plot.Grid = append(plot.Grid, style)
+	}
+
+	// Construct the axis labels at the positions of the cells they sit under, from
+	// the first cell at the window minimum to the last at the maximum
+	xformat := labelFormat(grid.p.labelfmt, (ep.xmax-ep.xmin)/(xlabels-1), math.Max(math.Abs(ep.xmin), math.Abs(ep.xmax)))
+	for i := range plot.Xlabel {
+		plot.Xlabel[i] = fmt.Sprintf(xformat, axisValue(cellFraction(i, xlabels), ep.xmin, ep.xmax))
+	}
+	yformat := labelFormat(grid.p.labelfmt, (ep.ymax-ep.ymin)/(ylabels-1), math.Max(math.Abs(ep.ymin), math.Abs(ep.ymax)))
+	for i := range plot.Ylabel {
+		plot.Ylabel[i] = fmt.Sprintf(yformat, axisValue(cellFraction(i, ylabels), ep.ymin, ep.ymax))
+	}
+	// The log-polar plot is labeled with the angle in degrees across and the radius
+	// about the center up
+	if grid.p.projection == "logpolar" {
+		for i := range plot.Xlabel {
+			theta, _ := logPolar(cellFraction(i, xlabels), 0, grid.p)
+			plot.Xlabel[i] = fmt.Sprintf("%.0f°", theta*180/math.Pi)
+		}
+		for i := range plot.Ylabel {
+			_, r := logPolar(0, 1-cellFraction(i, ylabels), grid.p)
+			plot.Ylabel[i] = fmt.Sprintf("%.3g", r)
+		}
+	}
+
+	htmlLegend(&plot, grid)
+	plot.Warning = iterationWarning(grid)
+
+	plot.Status = fmt.Sprintf("Status: Data plotted from (%v,%v) to (%v,%v)", ep.xmin, ep.ymin, ep.xmax, ep.ymax)
+	for _, e := range errs {
+		plot.Errors = append(plot.Errors, e.Message)
+	}
+	if len(errs) > 0 {
+		plot.Status = fmt.Sprintf("Status: %d invalid parameters replaced by defaults, data plotted from (%v,%v) to (%v,%v)",
+			len(errs), ep.xmin, ep.ymin, ep.xmax, ep.ymax)
+	}
+
+	// Write to HTTP using template and grid.  A client that goes away fails the
+	// write of its own request only.
+	return t.Execute(w, plot)
+}
+
+// labelFormat is the format of the axis labels that are step apart, up to size in
+// magnitude.  The auto notation keeps two decimals while they tell the labels
+// apart, adds decimals for a smaller step up to sciDigits and is scientific beyond.
+// The scientific notation has at least three decimals, more if the step needs them.
+func labelFormat(notation string, step, size float64) string {
+	const sciDigits = 6 // most decimals of the auto notation before it is scientific
+	if notation == "fixed" {
+		return "%.2f"
+	}
+	digits := 2
+	if step > 0 && step < .01 {
+		digits = int(math.Ceil(-math.Log10(step)))
+	}
+	if notation == "auto" && digits <= sciDigits {
+		return fmt.Sprintf("%%.%df", digits)
+	}
+	digits = 3
+	if step > 0 && size/step >= 1000 {
+		digits = int(math.Ceil(math.Log10(size / step)))
+	}
+	return fmt.Sprintf("%%.%de", digits)
+}
+
+// gridLayout sizes the HTML grid and its labels for the plot and places the axis
+// ticks at the cells of the interior labels, on the left edge and the bottom
+// row, the y labels counted from the bottom.  The cells
+// are cellSize/dpr CSS pixels, so a plot rendered at dpr times the resolution
+// keeps its size on the page.
+func gridLayout(rows, columns int, dpr float64) template.CSS {
+	cell := cellSize / dpr
+	width := math.Round(float64(columns) * cell)
+	height := math.Round(float64(rows) * cell)
+	var b strings.Builder
+	fmt.Fprintf(&b, "div.grid { grid-template-columns: repeat(%d, %.4gpx); grid-template-rows: repeat(%d, %.4gpx); width: %gpx; height: %gpx; }\n",
+		columns, cell, rows, cell, width, height)
+	fmt.Fprintf(&b, "#gridxlabel { width: %gpx; }\n", width+15)
+	fmt.Fprintf(&b, "#xlabel-container, #legend, #legend-labels { width: %gpx; }\n", width)
+	fmt.Fprintf(&b, "div.xlabel { flex: 0 0 %gpx; }\n", math.Floor(width/(xlabels-1)))
+	fmt.Fprintf(&b, "div.ylabel { flex: 0 0 %gpx; }\n", math.Floor(height/(ylabels-1)))
+
+	ticks := func(n, labels int, child func(int) int, border string) {
+		sel := make([]string, 0, labels-2)
+		for i := 1; i < labels-1; i++ {
+			sel = append(sel, fmt.Sprintf(".grid div:nth-child(%d)", child(labelCell(i, n, labels))))
+		}
+		fmt.Fprintf(&b, "%s { %s: 2px solid black; }\n", strings.Join(sel, ", "), border)
+	}
+	// y-axis ticks
+	ticks(rows, ylabels, func(row int) int { return (rows-1-row)*columns + 1 }, "border-bottom")
+	// x-axis ticks
+	ticks(columns, xlabels, func(col int) int { return (rows-1)*columns + col + 1 }, "border-left")
+	return template.CSS(b.String())
+}
+
+// labelCell is the cell of n nearest label i of labels, whose coordinate is that
+// of the label
+func labelCell(i, n, labels int) int {
+	return int(math.Round(float64(i*(n-1)) / float64(labels-1)))
+}
+
+// writePNG draws the grid as an image with one pixel per cell, with an 8-bit
+// palette for pngmode=palette.  A plain binary plot is a 1-bit paletted PNG.
+func writePNG(w io.Writer, grid *Grid) error {
+	p := grid.p
+	if plainBinary(grid) {
+		pal := color.Palette{binaryColors[0], binaryColors[1]}
+		if p.clearset {
+			pal[1] = transparent
+		}
+		img := image.NewPaletted(image.Rect(0, 0, p.columns, p.rows), pal)
+		for i := range grid.its {
+			img.Pix[i] = uint8(membership(grid, i))
+		}
+		return png.Encode(w, img)
+	}
+	img := pngImage(grid)
+	if p.paletted {
+		pal := sharedPalette([]*image.RGBA{img}, schemePalette(p))
+		paletted := image.NewPaletted(img.Rect, pal)
+		draw.Draw(paletted, img.Rect, img, image.Point{}, draw.Src)
+		return png.Encode(w, paletted)
+	}
+	return png.Encode(w, img)
+}
+
+// plainBinary reports whether the grid is a binary plot of the set membership
+// alone, one cell per pixel with nothing colored or drawn over it
+func plainBinary(grid *Grid) bool {
+	p := grid.p
+	switch {
+	case p.coloring != "binary" || p.ssaa != 1:
+		return false
+	case p.highlight || p.bgtint > 0 || grid.slow != nil || grid.failed || grid.layer != nil:
+		return false
+	case p.circle || p.vignette > 0 || p.axes || grid.inset != nil || p.annotate || p.legend && hasLegend(p):
+		return false
+	}
+	return true
+}
+
+// schemePalette is 256 colors of the active color scheme, for the images with
+// too many colors of their own:  the highlight and transparent colors and colors
+// evenly spaced along the gradient of the palette.  The angle coloring goes around the hue
+// wheel instead of the palette, so it is mapped to the Plan 9 palette.
+func schemePalette(p *Params) color.Palette {
+	if p.coloring == "angle" {
+		return palette.Plan9
+	}
+	var pal color.Palette
+	if p.highlight {
+		pal = append(pal, p.hcolor)
+	}
+	if p.clearset {
+		pal = append(pal, transparent)
+	}
+	n := 256 - len(pal)
+	for i := 0; i < n; i++ {
+		pal = append(pal, gradient(palettes[p.palette], float64(i)/float64(n-1)))
+	}
+	return pal
+}
+
+// writeJSON sends the grid iterations and the window they were computed for
+func writeJSON(w io.Writer, grid *Grid) error {
+	return json.NewEncoder(w).Encode(plotJSON(grid))
+}
+
+// plotJSON is the JSON form of the grid
+func plotJSON(grid *Grid) PlotJSON {
+	plot := PlotJSON{
+		Xmin:       grid.p.ep.xmin,
+		Xmax:       grid.p.ep.xmax,
+		Ymin:       grid.p.ep.ymin,
+		Ymax:       grid.p.ep.ymax,
+		Rows:       grid.p.rows,
+		Columns:    grid.p.columns,
+		Minits:     grid.minits,
+		Maxits:     grid.maxits,
+		Iterations: grid.its,
+		SetBounds:  setBounds(grid),
+	}
+	if grid.p.jsonvalue != "raw" {
+		plot.Iterations, plot.Values = nil, jsonValues(grid)
+	}
+	if grid.p.includez {
+		plot.Z = make([]*[2]float64, len(grid.its))
+		for i, its := range grid.its {
+			if its != grid.p.iterations && its != failedIts {
+				plot.Z[i] = &[2]float64{real(grid.z[i]), imag(grid.z[i])}
+			}
+		}
+	}
+	return plot
+}
+
+// jsonValues is the value of every cell for jsonvalue=normalized or smooth.  The
+// normalized value is the position of the iterations in the color range, clamped
+// to 0 to 1, with the members of the set at 1.  The smooth value is the fractional
+// escape iteration, the iteration cap for the members.  Failed cells are -1.
+func jsonValues(grid *Grid) []float64 {
+	p := grid.p
+	lo, hi := grid.colorRange()
+	values := make([]float64, len(grid.its))
+	for i, its := range grid.its {
+		switch {
+		case its == failedIts:
+			values[i] = failedIts
+		case its == p.iterations && p.jsonvalue == "normalized":
+			values[i] = 1
+		case its == p.iterations:
+			values[i] = float64(its)
+		case p.jsonvalue == "smooth":
+			values[i] = smoothIterations(grid, i)
+		case hi > lo:
+			values[i] = math.Max(0, math.Min(1, grid.normalize(float64(its))))
+		}
+	}
+	return values
+}
+
+// setBounds is the tight bounding box of the points of the cells that reached the
+// iteration cap, to frame a view on the set, or nil if no cell did
+func setBounds(grid *Grid) *Bounds {
+	p := grid.p
+	var b *Bounds
+	for i, its := range grid.its {
+		if its != p.iterations {
+			continue
+		}
+		z := cellPoint(i/p.columns, i%p.columns, p)
+		x, y := real(z), imag(z)
+		if b == nil {
+			b = &Bounds{x, x, y, y}
+			continue
+		}
+		b.Xmin = math.Min(b.Xmin, x)
+		b.Xmax = math.Max(b.Xmax, x)
+		b.Ymin = math.Min(b.Ymin, y)
+		b.Ymax = math.Max(b.Ymax, y)
+	}
+	return b
+}
+
+// writeNPY sends the grid iterations as a NumPy .npy file of int32 with shape
+// (rows, columns), which loads directly with numpy.load.  The layout is the magic
+// string, the format version 1.0, the little-endian header length and a Python
+// dict header padded with spaces to a multiple of 64 bytes, then the raw data.
+func writeNPY(w io.Writer, grid *Grid) error {
+	header := fmt.Sprintf("{'descr': '<i4', 'fortran_order': False, 'shape': (%d, %d), }", grid.p.rows, grid.p.columns)
+	const preamble = 10 // magic, version and header length
+	pad := 64 - (preamble+len(header)+1)%64
+	header += strings.Repeat(" ", pad%64) + "\n"
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString("\x93NUMPY\x01\x00")
+	binary.Write(bw, binary.LittleEndian, uint16(len(header)))
+	bw.WriteString(header)
+	data := make([]int32, len(grid.its))
+	for i, its := range grid.its {
+		data[i] = int32(its)
+	}
+	if err := binary.Write(bw, binary.LittleEndian, data); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// writePoints sends the members of the set, the cells that reached the iteration
+// cap, as a JSON array of their [re, im] points in the complex plane from
+// cellPoint, row by row from the top left
+func writePoints(w io.Writer, grid *Grid) error {
+	p := grid.p
+	bw := bufio.NewWriter(w)
+	bw.WriteByte('[')
+	var buf []byte
+	for i, its := range grid.its {
+		if its != p.iterations {
+			continue
+		}
+		if len(buf) > 0 {
+			bw.WriteByte(',')
+		}
+		z := cellPoint(i/p.columns, i%p.columns, p)
+		buf = append(buf[:0], '[')
+		buf = strconv.AppendFloat(buf, real(z), 'g', -1, 64)
+		buf = append(buf, ',')
+		buf = strconv.AppendFloat(buf, imag(z), 'g', -1, 64)
+		buf = append(buf, ']')
+		bw.Write(buf)
+	}
+	bw.WriteString("]\n")
+	return bw.Flush()
+}
+
+// asciiRamp are the characters of format=ascii from the fastest escaping cells to
+// the members of the set
+const asciiRamp = " .:-=+*#%@"
+
+// writeASCII sends the grid as lines of text, one character per cell, mapping the
+// normalized iterations to the character ramp.  Terminal characters are about
+// twice as tall as they are wide, so a height of half the width keeps the aspect.
+func writeASCII(w io.Writer, grid *Grid) error {
+	p := grid.p
+	lo, hi := grid.colorRange()
+	bw := bufio.NewWriter(w)
+	for i, its := range grid.its {
+		k := 0
+		switch {
+		case its == p.iterations:
+			k = len(asciiRamp) - 1
+		case hi > lo:
+			v := p.adjust(grid.normalize(float64(its)))
+			k = int(math.Max(0, math.Min(1, v))*float64(len(asciiRamp)-1) + .5)
+		}
+		bw.WriteByte(asciiRamp[k])
+		if (i+1)%p.columns == 0 {
+			bw.WriteByte('\n')
+		}
+	}
+	return bw.Flush()
+}
+
+// BinaryHeader starts the format=binary output, followed by the row-major cell
+// iterations as rows x columns little-endian int32
+type BinaryHeader struct {
+	Magic   [4]byte // "MBIT"
+	Version uint32  // 1
+	Columns uint32
+	Rows    uint32
+	Xmin    float64
+	Xmax    float64
+	Ymin    float64
+	Ymax    float64
+	Maxiter uint32 // iterations of the cells in the set
+}
+
+// writeBinary sends the grid as the binary layout of BinaryHeader, 52 bytes
+// little-endian, and the iterations, which a client reads without parsing
+func writeBinary(w io.Writer, grid *Grid) error {
+	p := grid.p
+	bw := bufio.NewWriter(w)
+	binary.Write(bw, binary.LittleEndian, BinaryHeader{
+		Magic:   [4]byte{'M', 'B', 'I', 'T'},
+		Version: 1,
+		Columns: uint32(p.columns),
+		Rows:    uint32(p.rows),
+		Xmin:    p.ep.xmin,
+		Xmax:    p.ep.xmax,
+		Ymin:    p.ep.ymin,
+		Ymax:    p.ep.ymax,
+		Maxiter: uint32(p.iterations),
+	})
+	data := make([]int32, len(grid.its))
+	for i, its := range grid.its {
+		data[i] = int32(its)
+	}
+	if err := binary.Write(bw, binary.LittleEndian, data); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// writeTIFF sends the grid iterations as a single channel 16-bit grayscale TIFF
+// with one pixel per cell, for analysis tools.  The pixel values are the iteration
+// counts, saturated at 65535, and the failed cells of a panicked row are 0.
+func writeTIFF(w io.Writer, grid *Grid) error {
+	img := image.NewGray16(image.Rect(0, 0, grid.p.columns, grid.p.rows))
+	for i, its := range grid.its {
+		switch {
+		case its == failedIts:
+			its = 0
+		case its > math.MaxUint16:
+			its = math.MaxUint16
+		}
+		img.Pix[2*i] = uint8(its >> 8)
+		img.Pix[2*i+1] = uint8(its)
+	}
+	return tiff.Encode(w, img, &tiff.Options{Compression: tiff.Deflate})
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"io"
+	"math"
+	"net/url"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/tiff"
+)
+
+// The invalid values echoed in the error list of the page and the window in its
+// status are escaped by the template
+func TestHTMLEscapesInput(t *testing.T) {
+	const attack = `"><script>alert(1)</script>`
+	q := url.Values{}
+	q.Set("width", "8")
+	q.Set("height", "8")
+	q.Set("coloring", attack)
+	q.Set("palette", attack)
+	q.Set("xstart", "-5e-324")
+	q.Set("xend", "5e-324")
+	q.Set("ystart", "-5e-324")
+	q.Set("yend", "5e-324")
+	w := serve(handlePlotting, pattern+"?"+q.Encode())
+	body := w.Body.String()
+	if w.Code != 200 {
+		t.Fatalf("status %d: %s", w.Code, body)
+	}
+	if strings.Contains(body, "<script>alert") {
+		t.Errorf("the page has the unescaped input %s", attack)
+	}
+	if !strings.Contains(body, "&lt;script&gt;alert(1)&lt;/script&gt;") {
+		t.Errorf("the page does not list the escaped invalid values")
+	}
+	if !strings.Contains(body, "data plotted from (-5e-324,-5e-324) to (5e-324,5e-324)") {
+		t.Errorf("the status does not echo the denormal window")
+	}
+}
+
+// The cells at the escape radius and of denormal coordinates take the iterations
+// of the escape test, with and without the main bulbs test
+func TestIterationEdges(t *testing.T) {
+	next := math.Nextafter(2, 3)
+	for _, optimize := range []string{"true", "false"} {
+		p := testParams(t, "maxiter=500&optimize="+optimize)
+		it := fractals[p.fractal].iterator(p)
+		for _, tc := range []struct {
+			c   complex128
+			its int
+		}{
+			{2, 1},                    // z1 = 2 is on the radius, z2 = 6 is outside
+			{complex(next, 0), 0},     // z1 is just outside the radius
+			{complex(0, 2), 1},        // z1 = 2i is on the radius, z2 = -4 + 2i is outside
+			{-2, 500},                 // the orbit stays at 2 on the radius
+			{0.25, 500},               // the cusp of the cardioid converges slowly
+			{complex(5e-324, 0), 500}, // the smallest denormal
+			{complex(-5e-324, 5e-324), 500},
+			{complex(2, 5e-324), 1}, // a denormal imaginary part does not change the escape
+		} {
+			its, z, _ := iteratePoint(tc.c, p, it)
+			if its != tc.its {
+				t.Errorf("optimize=%s: %v took %d iterations, want %d", optimize, tc.c, its, tc.its)
+			}
+			if math.IsNaN(real(z)) || math.IsNaN(imag(z)) {
+				t.Errorf("optimize=%s: %v ends at %v", optimize, tc.c, z)
+			}
+		}
+	}
+}
+
+// The binary plot decodes to the header of the plot and the grid iterations
+func TestBinaryRoundTrip(t *testing.T) {
+	const query = "width=37&height=23&maxiter=5000&xstart=-0.8&xend=-0.7&ystart=0.05&yend=0.15"
+	w := serve(handlePlotting, pattern+"?format=binary&"+query)
+	if w.Code != 200 {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	if n := w.Body.Len(); n != binary.Size(BinaryHeader{})+4*37*23 {
+		t.Errorf("%d bytes for %d cells", n, 37*23)
+	}
+	var header BinaryHeader
+	r := bytes.NewReader(w.Body.Bytes())
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		t.Fatal(err)
+	}
+	want := BinaryHeader{[4]byte{'M', 'B', 'I', 'T'}, 1, 37, 23, -0.8, -0.7, 0.05, 0.15, 5000}
+	if header != want {
+		t.Errorf("header %+v, want %+v", header, want)
+	}
+	data := make([]int32, header.Rows*header.Columns)
+	if err := binary.Read(r, binary.LittleEndian, data); err != nil {
+		t.Fatal(err)
+	}
+	if r.Len() != 0 {
+		t.Errorf("%d bytes after the iterations", r.Len())
+	}
+	grid := computeGrid(testParams(t, query))
+	for i, its := range grid.its {
+		if int(data[i]) != its {
+			t.Fatalf("cell %d decoded %d iterations, the grid has %d", i, data[i], its)
+		}
+	}
+}
+
+// BenchmarkPlotHTML is the page of the default plot, whose cells share a few
+// hundred colors
+func BenchmarkPlotHTML(b *testing.B) {
+	grid := computeGrid(testParams(b, ""))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := plotHTML(io.Discard, grid, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// The TIFF pixels are the iterations of the cells, 0 for a failed cell
+func TestTIFFPixels(t *testing.T) {
+	grid := computeGrid(testParams(t, "width=12&height=8&maxiter=300"))
+	grid.its[5] = failedIts
+	var buf bytes.Buffer
+	if err := writeTIFF(&buf, grid); err != nil {
+		t.Fatal(err)
+	}
+	img, err := tiff.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, its := range grid.its {
+		want := its
+		if its == failedIts {
+			want = 0
+		}
+		if v := int(img.(*image.Gray16).Gray16At(i%12, i/12).Y); v != want {
+			t.Errorf("cell %d is %d in the TIFF, want %d", i, v, want)
+		}
+	}
+}
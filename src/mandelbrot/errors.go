@@ -0,0 +1,96 @@
+// Machine readable errors of the plot parameters.  The browser keeps plotting
+// with the defaults for the invalid values, API clients get a JSON envelope with
+// an error code they can act on.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// error codes of the JSON error envelope
+const (
+	ErrNotNumber     = "ERR_NOT_NUMBER"     // the value does not parse as a number
+	ErrOutOfRange    = "ERR_OUT_OF_RANGE"   // the number is outside its allowed range
+	ErrXRange        = "ERR_X_RANGE"        // the x endpoints are outside the fractal's window
+	ErrYRange        = "ERR_Y_RANGE"        // the y endpoints are outside the fractal's window
+	ErrInvertedRange = "ERR_INVERTED_RANGE" // the start of a range is not below its end
+	ErrUnknownValue  = "ERR_UNKNOWN_VALUE"  // the name is not one of the registered choices
+	ErrNotApplicable = "ERR_NOT_APPLICABLE" // the parameter does not apply to the fractal
+	ErrBadRequest    = "ERR_BAD_REQUEST"    // the form or JSON body does not parse
+	ErrUnknownFormat = "ERR_UNKNOWN_FORMAT" // the format parameter names no encoder
+	ErrTooLarge      = "ERR_TOO_LARGE"      // the plot is too large for the request
+	ErrMethod        = "ERR_METHOD"         // the endpoint does not serve the HTTP method
+	ErrQueueFull     = "ERR_QUEUE_FULL"     // no more jobs can be queued for now
+	ErrNotFound      = "ERR_NOT_FOUND"      // the job does not exist or has expired
+	ErrNotReady      = "ERR_NOT_READY"      // the job result is not rendered yet
+)
+
+// ParamError is an invalid plot parameter, or an invalid request as a whole when
+// Param is empty
+type ParamError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Param   string `json:"param,omitempty"`
+}
+
+func (e *ParamError) Error() string {
+	return e.Message
+}
+
+// ParamErrors are the invalid parameters of a request in the order they were parsed
+type ParamErrors []*ParamError
+
+// add logs the error and appends it
+func (errs *ParamErrors) add(code, param, format string, args ...interface{}) {
+	e := &ParamError{Code: code, Message: fmt.Sprintf(format, args...), Param: param}
+	fmt.Printf("error: %s\n", e.Message)
+	*errs = append(*errs, e)
+}
+
+// fields are the error messages keyed by their parameter
+func (errs ParamErrors) fields() map[string][]string {
+	fields := make(map[string][]string)
+	for _, e := range errs {
+		fields[e.Param] = append(fields[e.Param], e.Message)
+	}
+	return fields
+}
+
+// numberCode is the code of a number that failed to parse (err) or is out of range
+func numberCode(err error) string {
+	if err != nil {
+		return ErrNotNumber
+	}
+	return ErrOutOfRange
+}
+
+// errorsEnvelope is the first of several errors with the messages of all of them
+// keyed by parameter, so a form can mark every invalid field at once
+type errorsEnvelope struct {
+	*ParamError
+	Fields map[string][]string `json:"fields"`
+}
+
+// writeError sends the error envelope with the HTTP status
+func writeError(w http.ResponseWriter, status int, e *ParamError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(e); err != nil {
+		fmt.Printf("error: write error response: %v\n", err)
+	}
+}
+
+// writeErrors sends the envelope of the parameter errors with the HTTP status,
+// the first error and the messages of all of them by parameter
+func writeErrors(w http.ResponseWriter, status int, errs ParamErrors) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(errorsEnvelope{errs[0], errs.fields()}); err != nil {
+		fmt.Printf("error: write error response: %v\n", err)
+	}
+}
@@ -0,0 +1,134 @@
+// Fixed-point iteration for fixedpoint=true.  Floating point results can differ
+// between architectures, for instance where the compiler fuses a multiply and add,
+// so the cell coordinates and the orbit are computed in int64 fixed point with
+// 56 fractional bits instead.  The integer operations are the same everywhere, so
+// the iterations are bit-identical on every platform.
+//
+// The representation covers -128 to 128 with a resolution of 2^-56, about
+// 1.4e-17, finer than float64 near the unit circle.  The squares are formed with
+// a 128-bit product, so the iteration is about three times slower than the float64
+// one, which also tests the squared modulus for escape.  The results differ from
+// float64 only for the cells whose orbits are chaotic near the boundary.
+// To stay in range, only the z^2 + c fractals are supported, with an escape radius
+// of at most 4 and the window, c and z0 within 4 of the origin.
+
+package main
+
+import (
+	"math"
+	"math/bits"
+)
+
+const (
+	fixedBits  = 56 // fractional bits of the fixed-point numbers
+	fixedLimit = 4  // largest escape radius and coordinate of the fixed-point iteration
+)
+
+// fixedpt is a signed fixed-point number with fixedBits fractional bits
+type fixedpt int64
+
+// toFixed is the fixed-point number nearest to x
+func toFixed(x float64) fixedpt {
+	return fixedpt(math.Round(math.Ldexp(x, fixedBits)))
+}
+
+// float is the value of the fixed-point number as a float64
+func (a fixedpt) float() float64 {
+	return math.Ldexp(float64(a), -fixedBits)
+}
+
+// mul is the product of the fixed-point numbers, truncated toward zero
+func (a fixedpt) mul(b fixedpt) fixedpt {
+	neg := (a < 0) != (b < 0)
+	ua, ub := uint64(a), uint64(b)
+	if a < 0 {
+		ua = uint64(-a)
+	}
+	if b < 0 {
+		ub = uint64(-b)
+	}
+	hi, lo := bits.Mul64(ua, ub)
+	r := fixedpt(hi<<(64-fixedBits) | lo>>fixedBits)
+	if neg {
+		return -r
+	}
+	return r
+}
+
+// fixedStep is the position of cell i of n across the nonnegative span d, the
+// fixed-point form of cellFraction.  A single cell samples the middle of the span.
+func fixedStep(d fixedpt, i, n int) fixedpt {
+	if n == 1 {
+		return d / 2
+	}
+	hi, lo := bits.Mul64(uint64(d), uint64(i))
+	q, _ := bits.Div64(hi, lo, uint64(n-1))
+	return fixedpt(q)
+}
+
+// fixedApplies checks that the plot can be iterated in fixed point, adding the
+// reason to the errors if not
+func fixedApplies(p *Params, errs *ParamErrors) bool {
+	ep := &p.ep
+	switch {
+	case p.fractal != "mandelbrot" && p.fractal != "julia":
+		errs.add(ErrNotApplicable, "fixedpoint", "fixedpoint does not apply to the %s fractal.", p.fractal)
+	case p.single:
+		errs.add(ErrNotApplicable, "fixedpoint", "fixedpoint does not apply with precision=float32.")
+	case p.coloring == "relief" || p.coloring == "firstentry":
+		errs.add(ErrNotApplicable, "fixedpoint", "fixedpoint does not apply to the %s coloring.", p.coloring)
+	case p.rotation != 1 || (p.ssaa > 1 && p.aapattern != "grid"):
+		errs.add(ErrNotApplicable, "fixedpoint", "fixedpoint does not apply to a rotated or jittered plot.")
+	case p.projection != "plane":
+		errs.add(ErrNotApplicable, "fixedpoint", "fixedpoint does not apply to the %s projection.", p.projection)
+	case p.radius > fixedLimit:
+		errs.add(ErrOutOfRange, "fixedpoint", "fixedpoint needs an escape radius of at most %d.", fixedLimit)
+	case math.Max(math.Max(math.Abs(ep.xmin), math.Abs(ep.xmax)), math.Max(math.Abs(ep.ymin), math.Abs(ep.ymax))) > fixedLimit ||
+		beyondFixed(p.c) || beyondFixed(p.z0):
+		errs.add(ErrOutOfRange, "fixedpoint", "fixedpoint needs the window, c and z0 within %d of the origin.", fixedLimit)
+	default:
+		return true
+	}
+	return false
+}
+
+// beyondFixed is true if a part of z is beyond the fixed-point coordinate limit
+func beyondFixed(z complex128) bool {
+	return math.Abs(real(z)) > fixedLimit || math.Abs(imag(z)) > fixedLimit
+}
+
+// iterateFixed is determineSet in fixed point.  The final z is converted back to
+// float64 for the colorings that use it, there is no derivative.
+func iterateFixed(row, col int, p *Params) (int, complex128, complex128) {
+	ep := &p.ep
+	xmin, xmax := toFixed(ep.xmin), toFixed(ep.xmax)
+	ymin, ymax := toFixed(ep.ymin), toFixed(ep.ymax)
+	cx := xmin + fixedStep(xmax-xmin, col, p.columns)
+	cy := ymax - fixedStep(ymax-ymin, row, p.rows)
+
+	// The Mandelbrot set iterates from z0 with the cell as the constant, the Julia
+	// set from the cell with a fixed constant
+	zx, zy := toFixed(real(p.z0)), toFixed(imag(p.z0))
+	if p.zstartc {
+		zx, zy = cx, cy
+	}
+	if p.julia {
+		zx, zy = cx, cy
+		cx, cy = toFixed(real(p.c)), toFixed(imag(p.c))
+	}
+
+	limit := p.iterations
+	if p.cutoff > 0 {
+		limit = int(math.Ceil(p.cutoff * float64(p.iterations)))
+	}
+	r := toFixed(p.radius)
+	r2 := r.mul(r)
+	for n := 0; n < limit; n++ {
+		zx, zy = zx.mul(zx)-zy.mul(zy)+cx, 2*zx.mul(zy)+cy
+		// A part beyond the radius escapes before the squares can overflow
+		if zx > r || zx < -r || zy > r || zy < -r || zx.mul(zx)+zy.mul(zy) > r2 {
+			return n, complex(zx.float(), zy.float()), 0
+		}
+	}
+	return p.iterations, complex(zx.float(), zy.float()), 0
+}
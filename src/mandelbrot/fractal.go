@@ -0,0 +1,200 @@
+// Fractal iteration formulas.  Each fractal is an Iterator registered by name in
+// fractals and selected with the fractal parameter.  Adding a fractal is a matter
+// of implementing Iterator and adding its entry to the registry.
+
+package main
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// Iterator is one step of the escape-time iteration z(n+1) = f(z(n), c)
+type Iterator interface {
+	Next(z, c complex128) complex128 // next value of the orbit
+	Escaped(z complex128) bool       // the orbit is unbounded
+}
+
+// Iterator32 is the single precision form of an Iterator, used for precision=float32
+type Iterator32 interface {
+	Next32(z, c complex64) complex64
+	Escaped32(z complex64) bool
+}
+
+// Differentiator is implemented by the analytic fractals to track the derivative
+// of the orbit, dz(n+1) = f'(z(n)) dz(n), used by the relief coloring
+type Differentiator interface {
+	Derivative(z, dz complex128) complex128
+}
+
+// Fractal is a registered fractal type
+type Fractal struct {
+	endpoints Endpoints              // default and widest plot window
+	julia     bool                   // the cell is z(0) and c is the Julia constant
+	iterator  func(*Params) Iterator // construct the iterator for the plot
+}
+
+var (
+	// fractals keyed by the fractal parameter
+	fractals = map[string]Fractal{
+		"mandelbrot": {
+			endpoints: Endpoints{-1.6, .8, -1.2, 1.2},
+			iterator:  func(p *Params) Iterator { return Mandelbrot{newBailout(p.radius)} },
+		},
+		"julia": {
+			endpoints: Endpoints{-1.6, 1.6, -1.2, 1.2},
+			julia:     true,
+			iterator:  func(p *Params) Iterator { return Mandelbrot{newBailout(p.radius)} },
+		},
+		"burningship": {
+			endpoints: Endpoints{-2.2, 1.3, -2.0, 1.0},
+			iterator:  func(p *Params) Iterator { return BurningShip{newBailout(p.radius)} },
+		},
+		"tricorn": {
+			endpoints: Endpoints{-2.2, 1.4, -1.8, 1.8},
+			iterator:  func(p *Params) Iterator { return Tricorn{newBailout(p.radius)} },
+		},
+		"celtic": {
+			endpoints: Endpoints{-2.0, 1.0, -1.5, 1.5},
+			iterator:  func(p *Params) Iterator { return Celtic{newBailout(p.radius)} },
+		},
+		"heart": {
+			endpoints: Endpoints{-1.6, .8, -1.2, 1.2},
+			iterator:  func(p *Params) Iterator { return Heart{newBailout(p.radius)} },
+		},
+		"multibrot": {
+			endpoints: Endpoints{-1.5, 1.5, -1.5, 1.5},
+			iterator:  func(p *Params) Iterator { return Multibrot{newBailout(p.radius), p.power} },
+		},
+	}
+)
+
+// bailout is the escape test shared by the fractals:  the orbit is unbounded
+// once the complex magnitude is greater than the escape radius (2 by default).
+// The squared magnitude is compared with the squared radius, which avoids the
+// square root of cmplx.Abs in the hot loop.
+type bailout struct {
+	r2    float64 // squared escape radius
+	r2f32 float32 // squared escape radius of the float32 orbits
+}
+
+// newBailout squares the escape radius.  A square beyond the largest float32 is
+// capped to it, so a float32 orbit whose squared magnitude overflows to +Inf
+// still escapes instead of comparing +Inf with +Inf.
+func newBailout(radius float64) bailout {
+	r2 := radius * radius
+	return bailout{r2: r2, r2f32: float32(math.Min(r2, math.MaxFloat32))}
+}
+
+func (b bailout) Escaped(z complex128) bool {
+	return real(z)*real(z)+imag(z)*imag(z) > b.r2
+}
+
+func (b bailout) Escaped32(z complex64) bool {
+	return real(z)*real(z)+imag(z)*imag(z) > b.r2f32
+}
+
+// Mandelbrot is z(n+1) = z(n)^2 + c, also used for the Julia sets
+type Mandelbrot struct{ bailout }
+
+func (Mandelbrot) Next(z, c complex128) complex128 {
+	return z*z + c
+}
+
+func (Mandelbrot) Derivative(z, dz complex128) complex128 {
+	return 2 * z * dz
+}
+
+func (Mandelbrot) Next32(z, c complex64) complex64 {
+	return z*z + c
+}
+
+// BurningShip squares the absolute values of the real and imaginary parts
+type BurningShip struct{ bailout }
+
+func (BurningShip) Next(z, c complex128) complex128 {
+	z = complex(math.Abs(real(z)), math.Abs(imag(z)))
+	return z*z + c
+}
+
+func (BurningShip) Next32(z, c complex64) complex64 {
+	z = complex(abs32(real(z)), abs32(imag(z)))
+	return z*z + c
+}
+
+// Tricorn (Mandelbar) squares the complex conjugate
+type Tricorn struct{ bailout }
+
+func (Tricorn) Next(z, c complex128) complex128 {
+	z = cmplx.Conj(z)
+	return z*z + c
+}
+
+func (Tricorn) Next32(z, c complex64) complex64 {
+	z = complex(real(z), -imag(z))
+	return z*z + c
+}
+
+// Celtic takes the absolute value of the real part of z^2
+type Celtic struct{ bailout }
+
+func (Celtic) Next(z, c complex128) complex128 {
+	z = z * z
+	return complex(math.Abs(real(z)), imag(z)) + c
+}
+
+func (Celtic) Next32(z, c complex64) complex64 {
+	z = z * z
+	return complex(abs32(real(z)), imag(z)) + c
+}
+
+// Heart squares z with the absolute value of only its real part
+type Heart struct{ bailout }
+
+func (Heart) Next(z, c complex128) complex128 {
+	z = complex(math.Abs(real(z)), imag(z))
+	return z*z + c
+}
+
+func (Heart) Next32(z, c complex64) complex64 {
+	z = complex(abs32(real(z)), imag(z))
+	return z*z + c
+}
+
+// Multibrot raises z to an integer power, 2 is the Mandelbrot set
+type Multibrot struct {
+	bailout
+	power int
+}
+
+func (m Multibrot) Next(z, c complex128) complex128 {
+	v := z
+	for i := 1; i < m.power; i++ {
+		v *= z
+	}
+	return v + c
+}
+
+func (m Multibrot) Derivative(z, dz complex128) complex128 {
+	v := complex(float64(m.power), 0)
+	for i := 1; i < m.power; i++ {
+		v *= z
+	}
+	return v * dz
+}
+
+func (m Multibrot) Next32(z, c complex64) complex64 {
+	v := z
+	for i := 1; i < m.power; i++ {
+		v *= z
+	}
+	return v + c
+}
+
+// abs32 is the absolute value of a float32
+func abs32(x float32) float32 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
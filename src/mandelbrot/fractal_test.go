@@ -0,0 +1,96 @@
+package main
+
+import (
+	"math"
+	"math/cmplx"
+	"testing"
+)
+
+// Celtic takes the absolute value of the real part of z squared and Heart that of
+// the real part of z before squaring, so both leave the Mandelbrot set at points
+// off the real axis where it stays bounded
+func TestCelticHeart(t *testing.T) {
+	z := complex(-1, 2) // z squared is -3 - 4i
+	for _, tc := range []struct {
+		fractal string
+		next    complex128
+	}{
+		{"mandelbrot", complex(-3, -4)},
+		{"celtic", complex(3, -4)},
+		{"heart", complex(-3, 4)},
+	} {
+		p := testParams(t, "fractal="+tc.fractal)
+		if next := fractals[tc.fractal].iterator(p).Next(z, 0); next != tc.next {
+			t.Errorf("%s: the next z of %v is %v, want %v", tc.fractal, z, next, tc.next)
+		}
+	}
+
+	for _, tc := range []struct {
+		c                         complex128
+		mandelbrot, celtic, heart int
+	}{
+		{complex(-0.2, 0.8), 200, 4, 2},
+		{complex(0.3, 0.5), 200, 3, 7},
+		{complex(-1.2, 0.2), 17, 200, 3},
+	} {
+		for fractal, want := range map[string]int{"mandelbrot": tc.mandelbrot, "celtic": tc.celtic, "heart": tc.heart} {
+			p := testParams(t, "maxiter=200&fractal="+fractal)
+			if its, _, _ := iteratePoint(tc.c, p, fractals[fractal].iterator(p)); its != want {
+				t.Errorf("%s: %v took %d iterations, want %d", fractal, tc.c, its, want)
+			}
+		}
+	}
+}
+
+// escapeSink keeps the escape benchmarks from being optimized away
+var escapeSink int
+
+// escapePoints are orbit points inside and outside the escape radius
+var escapePoints = []complex128{complex(0.3, 0.5), complex(-1.9, 0.7), complex(1.5, 1.4), complex(0, 2), complex(-0.1, -0.2), complex(2.5, -3)}
+
+// BenchmarkEscapeAbs is the escape test with the modulus, which takes a square root
+func BenchmarkEscapeAbs(b *testing.B) {
+	n := 0
+	for i := 0; i < b.N; i++ {
+		if cmplx.Abs(escapePoints[i%len(escapePoints)]) > 2 {
+			n++
+		}
+	}
+	escapeSink = n
+}
+
+// BenchmarkEscapeSquared is the escape test of bailout, with the squared modulus
+func BenchmarkEscapeSquared(b *testing.B) {
+	n := 0
+	escape := newBailout(2)
+	for i := 0; i < b.N; i++ {
+		if escape.Escaped(escapePoints[i%len(escapePoints)]) {
+			n++
+		}
+	}
+	escapeSink = n
+}
+
+// The squared escape test agrees with the modulus, also on the radius
+func TestEscapeSquared(t *testing.T) {
+	for _, z := range append(escapePoints, 2, complex(0, -2), complex(math.Nextafter(2, 3), 0)) {
+		if got, want := newBailout(2).Escaped(z), cmplx.Abs(z) > 2; got != want {
+			t.Errorf("%v escaped %v, |z| > 2 is %v", z, got, want)
+		}
+	}
+}
+
+// A point outside the set escapes with the largest escape radius also in float32,
+// whose squared radius is beyond the largest float32
+func TestEscapeLargeRadius(t *testing.T) {
+	for _, precision := range []string{"float64", "float32"} {
+		p := testParams(t, "maxiter=100&radius=1e150&precision="+precision)
+		it := fractals[p.fractal].iterator(p)
+		if its, _, _ := iteratePoint(1, p, it); its >= p.iterations {
+			t.Errorf("%s: 1 took %d iterations of %d", precision, its, p.iterations)
+		}
+		if its, _, _ := iteratePoint(-1, p, it); its != p.iterations {
+			t.Errorf("%s: -1 took %d iterations of %d", precision, its, p.iterations)
+		}
+	}
+}
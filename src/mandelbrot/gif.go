@@ -0,0 +1,120 @@
+// Animated GIF zooms.  /mandelbrot/zoom.gif renders frames of the plot window
+// zooming in toward a target point and sends them as one animated GIF.  The
+// frames share one color range and one palette so the colors hold steady.
+
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"net/http"
+)
+
+const (
+	patternGIF   = "/mandelbrot/zoom.gif" // http handler pattern for the zoom animation
+	gifFrames    = 30                     // default number of frames
+	maxGIFFrames = 240                    // most frames of an animation
+	gifDelay     = 10                     // default frame delay in 100ths of a second
+	gifZoom      = 1.1                    // default window size ratio between frames
+	maxGIFCells  = 16 << 20               // most cells of all the frames together
+)
+
+// handleGIF renders the zoom animation.  The window of each frame is the one
+// before scaled by 1/framezoom about the target (targetx, targety, the window
+// center by default), so the target stays put while the plot grows around it.
+func handleGIF(w http.ResponseWriter, r *http.Request) {
+	form, err := requestForm(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, &ParamError{Code: ErrBadRequest, Message: fmt.Sprintf("request parameters: %v", err)})
+		return
+	}
+	p, errs := parseParams(form)
+	ep := p.ep
+	frames, err := parseIntDefault(form.Get("frames"), gifFrames)
+	if err != nil || frames < 1 || frames > maxGIFFrames {
+		errs.add(numberCode(err), "frames", "frames %q is not an integer from 1 to %d.", form.Get("frames"), maxGIFFrames)
+	}
+	delay, err := parseIntDefault(form.Get("delay"), gifDelay)
+	if err != nil || delay < 0 {
+		errs.add(numberCode(err), "delay", "delay %q is not a nonnegative integer.", form.Get("delay"))
+	}
+	zoom, err := parseFloatDefault(form.Get("framezoom"), gifZoom)
+	if err != nil || zoom < 1 {
+		errs.add(numberCode(err), "framezoom", "frame zoom %q is not a number of at least 1.", form.Get("framezoom"))
+	}
+	tx, err1 := parseFloatDefault(form.Get("targetx"), (ep.xmin+ep.xmax)/2)
+	ty, err2 := parseFloatDefault(form.Get("targety"), (ep.ymin+ep.ymax)/2)
+	if err1 != nil || err2 != nil {
+		errs.add(ErrNotNumber, "targetx", "target (%q,%q) is not a point.", form.Get("targetx"), form.Get("targety"))
+	} else if tx < ep.xmin || tx > ep.xmax || ty < ep.ymin || ty > ep.ymax {
+		errs.add(ErrOutOfRange, "targetx", "target (%v,%v) is not inside the window.", tx, ty)
+	}
+	if len(errs) > 0 {
+		writeErrors(w, http.StatusBadRequest, errs)
+		return
+	}
+	if cells := frames * p.rows * p.columns; cells > maxGIFCells || p.rows > maxSize*p.ssaa || p.columns > maxSize*p.ssaa {
+		writeError(w, http.StatusBadRequest, &ParamError{Code: ErrTooLarge,
+			Message: fmt.Sprintf("the animation of %d frames of %d x %d cells is larger than %d cells", frames, p.columns, p.rows, maxGIFCells)})
+		return
+	}
+
+	// A fixed color range keeps the colors of a feature the same in every frame
+	if p.colormin < 0 {
+		p.colormin = 0
+	}
+	if p.colormax < 0 {
+		p.colormax = p.iterations
+	}
+
+	images := make([]*image.RGBA, frames)
+	scale := 1.0
+	for k := range images {
+		frame := *p
+		frame.ep = Endpoints{tx + (ep.xmin-tx)*scale, tx + (ep.xmax-tx)*scale, ty + (ep.ymin-ty)*scale, ty + (ep.ymax-ty)*scale}
+		images[k] = plotImage(computeGrid(&frame))
+		scale /= zoom
+		if err := r.Context().Err(); err != nil {
+			fmt.Printf("error: zoom animation canceled after %d frames: %v\n", k+1, err)
+			return
+		}
+	}
+
+	pal := sharedPalette(images, palette.Plan9)
+	anim := gif.GIF{Image: make([]*image.Paletted, frames), Delay: make([]int, frames)}
+	for k, img := range images {
+		anim.Image[k] = image.NewPaletted(img.Rect, pal)
+		draw.Draw(anim.Image[k], img.Rect, img, image.Point{}, draw.Src)
+		anim.Delay[k] = delay
+	}
+	fmt.Printf("Zoom animation: %d frames toward (%v,%v) with %d colors\n", frames, tx, ty, len(pal))
+
+	w.Header().Set("Content-Type", "image/gif")
+	if err := gif.EncodeAll(w, &anim); err != nil {
+		fmt.Printf("error: write zoom animation: %v\n", err)
+	}
+}
+
+// sharedPalette is the palette of all the frames:  their own colors if they use
+// at most 256, otherwise the fallback palette the colors are mapped to.
+func sharedPalette(images []*image.RGBA, fallback color.Palette) color.Palette {
+	seen := make(map[color.RGBA]bool)
+	var pal color.Palette
+	for _, img := range images {
+		for i := 0; i < len(img.Pix); i += 4 {
+			c := color.RGBA{img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3]}
+			if !seen[c] {
+				if len(pal) == 256 {
+					return fallback
+				}
+				seen[c] = true
+				pal = append(pal, c)
+			}
+		}
+	}
+	return pal
+}
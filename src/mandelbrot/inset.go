@@ -0,0 +1,98 @@
+// Zoomed insets.  With inset=xmin,xmax,ymin,ymax the plot is drawn with a
+// magnified view of that window in a corner, framed in the inset color, and the
+// region it magnifies is outlined on the plot.  The inset is a second grid
+// computed with the same parameters, at insetsize of the plot width.
+
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+const insetMargin = 8 // pixels between the inset and the edges of the plot
+
+var insetColor = color.RGBA{0xff, 0x00, 0x00, 0xff} // default frame of the inset, red
+
+// insetGrid computes the grid of the plot and the grid of the inset window
+func insetGrid(p *Params) *Grid {
+	base := *p
+	base.insetwin = Endpoints{}
+	grid := computeGrid(&base)
+	grid.p = p
+	grid.inset = computeGrid(insetParams(p))
+	return grid
+}
+
+// insetParams are the parameters of the inset grid of the plot.  The inset keeps
+// the aspect ratio of its window within the plot and has no mask or axes of its
+// own.
+func insetParams(p *Params) *Params {
+	other := *p
+	other.insetwin = Endpoints{}
+	other.ep = p.insetwin
+	other.circle, other.vignette, other.axes = false, 0, false
+	n := p.ssaa
+	aspect := (p.insetwin.ymax - p.insetwin.ymin) / (p.insetwin.xmax - p.insetwin.xmin)
+	width := math.Round(p.insetsize * float64(p.columns/n))
+	height := math.Min(math.Round(width*aspect), float64(p.rows/n))
+	other.columns = int(math.Max(1, width)) * n
+	other.rows = int(math.Max(1, height)) * n
+	return &other
+}
+
+// insetPixels draws the inset in its corner of the image with a frame, and the
+// outline of the window it magnifies where the plot is not rotated or projected
+func insetPixels(img *image.RGBA, grid *Grid) {
+	p := grid.p
+	top := plotImage(grid.inset)
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	iw, ih := top.Rect.Dx(), top.Rect.Dy()
+
+	if p.rotation == 1 && p.projection == "plane" {
+		// The pixel of a coordinate, with the mapping of the cells
+		pixel := func(v, lo, hi float64, n int) int {
+			return int(math.Round((v - lo) / (hi - lo) * float64(n-1)))
+		}
+		x0, x1 := pixel(p.insetwin.xmin, p.ep.xmin, p.ep.xmax, w), pixel(p.insetwin.xmax, p.ep.xmin, p.ep.xmax, w)
+		y0, y1 := pixel(p.insetwin.ymax, p.ep.ymax, p.ep.ymin, h), pixel(p.insetwin.ymin, p.ep.ymax, p.ep.ymin, h)
+		frame(img, image.Rect(x0-1, y0-1, x1+2, y1+2), p.icolor)
+	}
+
+	x, y := insetMargin, insetMargin
+	if p.insetpos == "tr" || p.insetpos == "br" {
+		x = w - insetMargin - iw
+	}
+	if p.insetpos == "bl" || p.insetpos == "br" {
+		y = h - insetMargin - ih
+	}
+	at := image.Rect(x, y, x+iw, y+ih)
+	for py := at.Min.Y; py < at.Max.Y; py++ {
+		for px := at.Min.X; px < at.Max.X; px++ {
+			if (image.Point{px, py}).In(img.Rect) {
+				img.SetRGBA(px, py, top.RGBAAt(px-x, py-y))
+			}
+		}
+	}
+	frame(img, at.Inset(-1), p.icolor)
+}
+
+// frame draws the one pixel border of the rectangle, clipped to the image
+func frame(img *image.RGBA, r image.Rectangle, c color.RGBA) {
+	clip := r.Intersect(img.Rect)
+	for x := clip.Min.X; x < clip.Max.X; x++ {
+		for _, y := range []int{r.Min.Y, r.Max.Y - 1} {
+			if (image.Point{x, y}).In(img.Rect) {
+				img.SetRGBA(x, y, c)
+			}
+		}
+	}
+	for y := clip.Min.Y; y < clip.Max.Y; y++ {
+		for _, x := range []int{r.Min.X, r.Max.X - 1} {
+			if (image.Point{x, y}).In(img.Rect) {
+				img.SetRGBA(x, y, c)
+			}
+		}
+	}
+}
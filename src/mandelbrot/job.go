@@ -0,0 +1,286 @@
+// Asynchronous poster renders.  Plots too large for a synchronous request are
+// POSTed to /mandelbrot/jobs with the usual plot parameters plus width and height.
+// The response is the job status with its id, which is polled at
+// /mandelbrot/jobs/{id} until the PNG is ready at /mandelbrot/jobs/{id}/png.
+
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image/png"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	patternJobs  = "/mandelbrot/jobs"    // http handler pattern for submitting jobs
+	patternJob   = "/mandelbrot/jobs/"   // http handler pattern for the job status and result
+	maxPoster    = 8192                  // largest width or height of a poster job
+	maxCells     = maxPoster * maxPoster // most cells of a poster job after ssaa and dpr
+	jobQueueSize = 16                    // jobs waiting for a worker before submissions are refused
+	jobTTL       = time.Hour             // time a finished job and its result are kept
+)
+
+// job states
+const (
+	jobQueued  = "queued"
+	jobRunning = "running"
+	jobDone    = "done"
+	jobFailed  = "failed"
+)
+
+// Job is one poster render.  The fields after p are guarded by the queue mutex.
+type Job struct {
+	id       string
+	p        *Params
+	state    string
+	err      error
+	created  time.Time
+	started  time.Time
+	finished time.Time
+	file     string // PNG result in the temporary directory
+}
+
+// JobStatus is the job as sent to the client
+type JobStatus struct {
+	ID       string     `json:"id"`
+	Status   string     `json:"status"`
+	Error    string     `json:"error,omitempty"`
+	Width    int        `json:"width"`
+	Height   int        `json:"height"`
+	Created  time.Time  `json:"created"`
+	Started  *time.Time `json:"started,omitempty"`
+	Finished *time.Time `json:"finished,omitempty"`
+	Elapsed  float64    `json:"elapsed_ms,omitempty"` // render time
+	Result   string     `json:"result,omitempty"`     // URL of the PNG once done
+}
+
+// JobQueue holds the submitted jobs and feeds them to the workers
+type JobQueue struct {
+	mu      sync.Mutex
+	jobs    map[string]*Job
+	pending chan *Job
+}
+
+var jobs *JobQueue // poster jobs keyed by id
+
+// newJobQueue creates the queue and starts the workers
+func newJobQueue(workers int) *JobQueue {
+	q := &JobQueue{jobs: make(map[string]*Job), pending: make(chan *Job, jobQueueSize)}
+	for i := 0; i < workers; i++ {
+		go q.work()
+	}
+	return q
+}
+
+// submit queues the render, it returns false if the queue is full
+func (q *JobQueue) submit(p *Params) (*Job, bool) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		fmt.Printf("error: job id: %v\n", err)
+		return nil, false
+	}
+	job := &Job{id: hex.EncodeToString(id), p: p, state: jobQueued, created: time.Now()}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	select {
+	case q.pending <- job:
+		q.jobs[job.id] = job
+		return job, true
+	default:
+		return nil, false
+	}
+}
+
+// get returns the job with the id
+func (q *JobQueue) get(id string) (*Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job, ok := q.jobs[id]
+	return job, ok
+}
+
+// work renders the pending jobs one at a time
+func (q *JobQueue) work() {
+	for job := range q.pending {
+		q.mu.Lock()
+		job.state = jobRunning
+		job.started = time.Now()
+		q.mu.Unlock()
+
+		file, err := func() (file string, err error) {
+			// A panic fails the job instead of ending the worker
+			defer func() {
+				if r := recover(); r != nil {
+					fmt.Printf("error: job %s panicked: %v\n%s", job.id, r, debug.Stack())
+					err = fmt.Errorf("the render panicked: %v", r)
+				}
+			}()
+			return renderPoster(job)
+		}()
+
+		q.mu.Lock()
+		job.finished = time.Now()
+		if err != nil {
+			fmt.Printf("error: job %s: %v\n", job.id, err)
+			job.state = jobFailed
+			job.err = err
+		} else {
+			job.state = jobDone
+			job.file = file
+		}
+		fmt.Printf("Job %s %s in %v\n", job.id, job.state, job.finished.Sub(job.started))
+		q.mu.Unlock()
+
+		// Forget the job and remove its result once it expires
+		id := job.id
+		time.AfterFunc(jobTTL, func() { q.expire(id) })
+	}
+}
+
+// expire removes the job and its result file
+func (q *JobQueue) expire(id string) {
+	q.mu.Lock()
+	job, ok := q.jobs[id]
+	delete(q.jobs, id)
+	q.mu.Unlock()
+	if ok && len(job.file) > 0 {
+		if err := os.Remove(job.file); err != nil {
+			fmt.Printf("error: remove job %s result: %v\n", id, err)
+		}
+	}
+}
+
+// renderPoster computes the job grid, bypassing the grid cache, and writes the
+// PNG to a temporary file.  With -streampng the rows are computed as they are
+// written instead.
+func renderPoster(job *Job) (string, error) {
+	f, err := os.CreateTemp("", "mandelbrot-"+job.id+"-*.png")
+	if err != nil {
+		return "", err
+	}
+	if *streamPNG && job.p.streamable() {
+		err = writeStream(f, job.p)
+	} else {
+		err = png.Encode(f, pngImage(computeGrid(job.p)))
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// info is the job as sent to the client, the queue mutex must be held
+func (job *Job) info() JobStatus {
+	js := JobStatus{
+		ID:      job.id,
+		Status:  job.state,
+		Width:   job.p.columns / job.p.ssaa,
+		Height:  job.p.rows / job.p.ssaa,
+		Created: job.created,
+	}
+	if job.err != nil {
+		js.Error = job.err.Error()
+	}
+	if !job.started.IsZero() {
+		js.Started = &job.started
+	}
+	if !job.finished.IsZero() {
+		js.Finished = &job.finished
+		js.Elapsed = ms(job.finished.Sub(job.started))
+	}
+	if job.state == jobDone {
+		js.Result = patternJob + job.id + "/png"
+	}
+	return js
+}
+
+// writeJobStatus sends the job status as JSON
+func writeJobStatus(w http.ResponseWriter, job *Job, code int) {
+	jobs.mu.Lock()
+	js := job.info()
+	jobs.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(js); err != nil {
+		fmt.Printf("error: write job status: %v\n", err)
+	}
+}
+
+// handleJobs submits a poster job with the plot parameters of the POST request
+func handleJobs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, &ParamError{Code: ErrMethod, Message: "jobs are submitted with POST"})
+		return
+	}
+	form, err := requestForm(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, &ParamError{Code: ErrBadRequest, Message: fmt.Sprintf("request parameters: %v", err)})
+		return
+	}
+	p, errs := parseParams(form)
+	if len(errs) > 0 {
+		writeErrors(w, http.StatusBadRequest, errs)
+		return
+	}
+	// Each side is bounded before the cells are counted so the product cannot overflow
+	side := float64(maxPoster*p.ssaa) * p.dpr
+	if p.rows < 1 || p.columns < 1 || float64(p.rows) > side || float64(p.columns) > side || p.rows*p.columns > maxCells {
+		writeError(w, http.StatusBadRequest, &ParamError{Code: ErrTooLarge,
+			Message: fmt.Sprintf("jobs of more than %d cells after ssaa and dpr are not rendered, the plot has %d x %d", maxCells, p.columns, p.rows)})
+		return
+	}
+
+	job, ok := jobs.submit(p)
+	if !ok {
+		writeError(w, http.StatusServiceUnavailable, &ParamError{Code: ErrQueueFull, Message: "the job queue is full, try again later"})
+		return
+	}
+	fmt.Printf("Job %s queued: %d x %d\n", job.id, job.p.columns/job.p.ssaa, job.p.rows/job.p.ssaa)
+	w.Header().Set("Location", patternJob+job.id)
+	writeJobStatus(w, job, http.StatusAccepted)
+}
+
+// handleJob sends the status of the job at /mandelbrot/jobs/{id} or its PNG at
+// /mandelbrot/jobs/{id}/png
+func handleJob(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, patternJob)
+	result := strings.HasSuffix(id, "/png")
+	id = strings.TrimSuffix(id, "/png")
+	job, ok := jobs.get(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, &ParamError{Code: ErrNotFound, Message: fmt.Sprintf("job %q does not exist", id)})
+		return
+	}
+	if !result {
+		writeJobStatus(w, job, http.StatusOK)
+		return
+	}
+
+	jobs.mu.Lock()
+	state, file := job.state, job.file
+	jobs.mu.Unlock()
+	if state != jobDone {
+		writeError(w, http.StatusConflict, &ParamError{Code: ErrNotReady, Message: fmt.Sprintf("job %s is %s", id, state)})
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"mandelbrot-%s.png\"", id))
+	http.ServeFile(w, r, file)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// A job whose render panics fails with the panic, and the worker goes on to the
+// next job
+func TestJobPanic(t *testing.T) {
+	fractals["broken"] = Fractal{
+		endpoints: fractals["mandelbrot"].endpoints,
+		iterator:  func(p *Params) Iterator { panic("injected panic") },
+	}
+	defer delete(fractals, "broken")
+
+	for _, tc := range []struct {
+		fractal, state string
+	}{
+		{"broken", jobFailed},
+		{"mandelbrot", jobDone},
+	} {
+		js := submitJob(t, "fractal="+tc.fractal+"&width=20&height=10")
+		for deadline := time.Now().Add(10 * time.Second); js.Status == jobQueued || js.Status == jobRunning; {
+			if time.Now().After(deadline) {
+				t.Fatalf("%s: the job is still %s", tc.fractal, js.Status)
+			}
+			time.Sleep(10 * time.Millisecond)
+			w := serve(handleJob, patternJob+js.ID)
+			if err := json.NewDecoder(w.Body).Decode(&js); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if js.Status != tc.state {
+			t.Errorf("%s: the job is %s: %s", tc.fractal, js.Status, js.Error)
+		}
+		if tc.state == jobFailed && !strings.Contains(js.Error, "injected panic") {
+			t.Errorf("%s: the job error is %q", tc.fractal, js.Error)
+		}
+	}
+}
+
+// Jobs of NaN or too many cells after dpr are refused
+func TestJobSize(t *testing.T) {
+	for _, query := range []string{
+		"width=20&height=10&dpr=NaN",
+		"width=8192&height=8192&dpr=4",
+		"width=8192&height=8192&ssaa=4",
+	} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, patternJobs, strings.NewReader(query))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		handleJobs(w, r)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d: %s", query, w.Code, w.Body)
+		}
+	}
+}
+
+// submitJob POSTs the job and returns its status
+func submitJob(t *testing.T, query string) JobStatus {
+	t.Helper()
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, patternJobs, strings.NewReader(query))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	handleJobs(w, r)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("%s: status %d: %s", query, w.Code, w.Body)
+	}
+	var js JobStatus
+	if err := json.NewDecoder(w.Body).Decode(&js); err != nil {
+		t.Fatal(err)
+	}
+	return js
+}
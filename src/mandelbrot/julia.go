@@ -0,0 +1,57 @@
+// Julia set previews for a Julia explorer.  A point c of a Mandelbrot plot, as
+// the one under the pointer, is sent to /mandelbrot/julia?x=...&y=... and the
+// Julia set of that constant is returned as a PNG thumbnail of its default window.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+const patternJulia = "/mandelbrot/julia" // http handler pattern for the Julia set of a point
+
+// handleJulia renders the Julia set with the constant c = (x,y).  The other plot
+// parameters, such as maxiter, palette, coloring and the window, apply as for
+// the plot, and the size is thumbSize unless width and height are given.
+func handleJulia(w http.ResponseWriter, r *http.Request) {
+	form, err := requestForm(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, &ParamError{Code: ErrBadRequest, Message: fmt.Sprintf("request parameters: %v", err)})
+		return
+	}
+	julia := url.Values{}
+	julia.Set("fractal", "julia")
+	for _, name := range [][2]string{{"x", "creal"}, {"y", "cimag"}} {
+		v := form.Get(name[0])
+		if _, err := strconv.ParseFloat(v, 64); err != nil {
+			writeError(w, http.StatusBadRequest, &ParamError{Code: ErrNotNumber,
+				Message: fmt.Sprintf("%s %q is not a number.", name[0], v), Param: name[0]})
+			return
+		}
+		julia.Set(name[1], v)
+	}
+	thumbDefault := url.Values{}
+	thumbDefault.Set("width", strconv.Itoa(thumbSize))
+	thumbDefault.Set("height", strconv.Itoa(thumbSize))
+
+	// The point overrides the fractal and constant of the form, which overrides
+	// the thumbnail size
+	p, errs := parseParams(forms{julia, form, thumbDefault})
+	if len(errs) > 0 {
+		writeErrors(w, http.StatusBadRequest, errs)
+		return
+	}
+	if limit := float64(maxSize*p.ssaa) * p.dpr; float64(p.rows) > limit || float64(p.columns) > limit {
+		writeError(w, http.StatusBadRequest, &ParamError{Code: ErrTooLarge,
+			Message: fmt.Sprintf("the Julia set is larger than %d x %d", maxSize, maxSize)})
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	if err := writePNG(w, renderGrid(p)); err != nil {
+		fmt.Printf("error: write Julia set: %v\n", err)
+	}
+}
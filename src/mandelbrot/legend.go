@@ -0,0 +1,119 @@
+// Color legend of the plot.  The legend is a bar of the colors from the fewest
+// to the most iterations in the plot, labeled with the iteration counts.  It is
+// shown under the HTML plot and drawn under the PNG with legend=true.
+
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"image"
+	"image/color"
+	"image/draw"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	legendCells  = 100 // color steps of the HTML legend bar
+	legendLabels = 5   // iteration labels under the bar
+	legendHeight = 32  // pixels added under the PNG plot for the legend
+	legendMargin = 8   // pixels between the PNG legend bar and the image edges
+	legendBar    = 10  // height of the PNG legend bar
+)
+
+// hasLegend is true if the coloring maps the iterations to the palette.  The
+// relief and potential colorings do not depend on the iteration counts alone, and
+// the bands of colormod repeat the palette and a channel has no palette.
+func hasLegend(p *Params) bool {
+	return (p.coloring == "iterations" && p.colormod == 0 && p.channel == "") || p.coloring == "smooth"
+}
+
+// legendColor is the color of the cells at v from 0 (the fewest iterations of the
+// color range) to 1 (the most)
+func legendColor(grid *Grid, v float64) color.RGBA {
+	palette := palettes[grid.p.palette]
+	if grid.p.coloring == "smooth" {
+		return gradient(palette, grid.p.adjust(v))
+	}
+	its := int(grid.denormalize(v) + .5)
+	return palette[colorIndex(its, grid)]
+}
+
+// legendLabel is the iteration count of label i of the legend
+func legendLabel(grid *Grid, i int) string {
+	return fmt.Sprintf("%.0f", grid.denormalize(float64(i)/(legendLabels-1)))
+}
+
+// htmlLegend sets the legend bar styles and labels of the HTML plot
+func htmlLegend(plot *PlotT, grid *Grid) {
+	if !hasLegend(grid.p) {
+		return
+	}
+	plot.Legend = make([]template.CSS, legendCells)
+	for i := range plot.Legend {
+		c := legendColor(grid, float64(i)/(legendCells-1))
+		plot.Legend[i] = template.CSS(fmt.Sprintf("background-color: #%02x%02x%02x", c.R, c.G, c.B))
+	}
+	plot.LegendLabel = make([]string, legendLabels)
+	for i := range plot.LegendLabel {
+		plot.LegendLabel[i] = legendLabel(grid, i)
+	}
+}
+
+// drawLegend extends the plot image with the legend bar and its labels below it
+func drawLegend(plot *image.RGBA, grid *Grid) *image.RGBA {
+	w, h := plot.Rect.Dx(), plot.Rect.Dy()
+	img := image.NewRGBA(image.Rect(0, 0, w, h+legendHeight))
+	draw.Draw(img, img.Rect, image.White, image.Point{}, draw.Src)
+	draw.Draw(img, plot.Rect, plot, image.Point{}, draw.Src)
+
+	// The bar spans the width inside the margins
+	x0, x1 := legendMargin, w-legendMargin
+	if x1 <= x0 {
+		x0, x1 = 0, w
+	}
+	top := h + 4
+	for x := x0; x < x1; x++ {
+		v := 0.0
+		if x1-x0 > 1 {
+			v = float64(x-x0) / float64(x1-x0-1)
+		}
+		c := legendColor(grid, v)
+		for y := top; y < top+legendBar; y++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+
+	// Labels centered under their positions on the bar, kept inside the image
+	d := font.Drawer{Dst: img, Src: image.Black, Face: basicfont.Face7x13}
+	for i := 0; i < legendLabels; i++ {
+		label := legendLabel(grid, i)
+		width := d.MeasureString(label).Ceil()
+		x := x0 + i*(x1-x0-1)/(legendLabels-1) - width/2
+		if x+width > w {
+			x = w - width
+		}
+		if x < 0 {
+			x = 0
+		}
+		d.Dot = fixed.P(x, top+legendBar+basicfont.Face7x13.Ascent+2)
+		d.DrawString(label)
+	}
+	return img
+}
+
+// pngImage is the plot image of the PNG output, with the annotation and the
+// legend if requested
+func pngImage(grid *Grid) *image.RGBA {
+	img := plotImage(grid)
+	if grid.p.annotate {
+		drawAnnotation(img, grid.p)
+	}
+	if grid.p.legend && hasLegend(grid.p) {
+		img = drawLegend(img, grid)
+	}
+	return img
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+)
+
+// TestMain sets up the caches and the job queue as main does for the server
+func TestMain(m *testing.M) {
+	if err := configure(""); err != nil {
+		panic(err)
+	}
+	grids = newLRU[Params, *Grid](*cacheSize)
+	tiles = newLRU[TileKey, []byte](*tileCache)
+	jobs = newJobQueue(1)
+	os.Exit(m.Run())
+}
+
+// testParams are the plot parameters of the query, which must be valid
+func testParams(t testing.TB, query string) *Params {
+	t.Helper()
+	form, err := url.ParseQuery(query)
+	if err != nil {
+		t.Fatalf("query %q: %v", query, err)
+	}
+	p, errs := parseParams(form)
+	if len(errs) > 0 {
+		t.Fatalf("query %q: %v", query, errs[0])
+	}
+	return p
+}
+
+// serve is the response of the handler to a GET of the target
+func serve(handler http.HandlerFunc, target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, target, nil))
+	return w
+}
@@ -1,47 +1,92 @@
 // mandelbrot plots the set of complex points that satisfy z(n+1) = z(n)^2 + c
 // as n goes to infinity and the complex magnitude is less than 2.  c = x + yi is the
-// x-y coordinate of the cell.  z(0) = c.  Plot options allow zooming to any point in the complex plane.
+// x-y coordinate of the cell.  z(0) = c.  Plot options allow zooming to any point in the complex plane
+// and selecting the Julia, Burning Ship, Tricorn and Multibrot fractals instead.
 
 package main
 
 import (
+	_ "embed"
+	"flag"
 	"fmt"
+	"html/template"
+	"image/color"
+	"io"
 	"log"
+	"math"
 	"math/cmplx"
 	"net/http"
+	"net/url"
+	"runtime/debug"
 	"strconv"
-	"text/template"
+	"strings"
 	"time"
 )
 
+// Defaults and limits that the -config file and the flags can change at startup
+var (
+	rows          = 300    // default #rows in grid
+	columns       = 300    // default #columns in grid
+	maxIterations = 200    // default maximum iterations to determine the Mandelbrot set
+	iterLimit     = 100000 // largest maxiter accepted from the request
+	maxSize       = 1024   // largest width or height of a synchronous plot
+)
+
 const (
-	rows          = 300                                            // #rows in grid
-	columns       = 300                                            // #columns in grid
-	tmpl          = "../../src/mandelbrot/templates/plotdata.html" // html template relative address
-	addr          = "127.0.0.1:8080"                               // http server listen address
-	pattern       = "/mandelbrot"                                  // http handler pattern for plotting data
-	xlabels       = 11                                             // # labels on x axis
-	ylabels       = 11                                             // # labels on y axis
-	maxIterations = 200                                            // maximum iterations to determine the Mandelbrot set
-	colors        = 5                                              // number of colors (shades of gray) in the Mandelbrot plot
+	tmpl         = "../../src/mandelbrot/templates/plotdata.html" // html template relative address
+	addr         = "127.0.0.1:8080"                               // http server listen address
+	pattern      = "/mandelbrot"                                  // http handler pattern for plotting data
+	xlabels      = 11                                             // # labels on x axis
+	ylabels      = 11                                             // # labels on y axis
+	radius       = 2.0                                            // default escape radius
+	maxRadius    = 1e150                                          // largest escape radius, whose square is a float64
+	maxSSAA      = 4                                              // largest supersampling factor
+	maxDownscale = 16                                             // largest block size of a downscaled grid
+	maxDPR       = 4.0                                            // largest device pixel ratio
+	maxContrast  = 10.0                                           // largest contrast of the colors
+	maxGamma     = 10.0                                           // largest gamma correction of the colors
+	zoomFactor   = 2.0                                            // default window size ratio of a zoom in or out step
 )
 
 // plot data that is parsed into the HTML template
 type PlotT struct {
-	Grid   []string // plotting grid
-	Status string   // status of the plot
-	Xlabel []string // x-axis labels
-	Ylabel []string // y-axis labels
+	Grid        []template.CSS // plotting grid cell styles
+	Layout      template.CSS   // grid size dependent styles
+	Status      string         // status of the plot
+	Errors      []string       // invalid parameters replaced by their defaults
+	Warning     string         // the plot is likely under-iterated, empty if not
+	Fractal     string         // name of the plotted fractal
+	Coloring    string         // coloring of the cells
+	Xstart      string         // current window, the starting point of a zoom
+	Xend        string
+	Ystart      string
+	Yend        string
+	Pinx        string // pinned point kept at the center, empty if none
+	Piny        string
+	Width       int // requested plot size, the cells are dpr times as many
+	Height      int
+	DPR         string         // device pixel ratio the cells are scaled down by
+	Xlabel      []string       // x-axis labels
+	Ylabel      []string       // y-axis labels
+	Legend      []template.CSS // legend bar cell styles, empty if the coloring has no legend
+	LegendLabel []string       // legend iteration labels
 }
 
 // Result sent in the channel from the goroutines
 type Result struct {
 	row    int
-	minits int   // minimum iteration for this row
-	maxits int   // maximum interation for this row
-	its    []int // cell iterations for this row
+	minits int          // minimum iteration for this row
+	maxits int          // maximum interation for this row
+	its    []int        // cell iterations for this row
+	z      []complex128 // final z of the cells if the coloring needs them
+	dz     []complex128 // derivative of the final z
+	ms     float64      // compute milliseconds of this row
+	failed bool         // the row panicked and its cells are failedIts
 }
 
+// failedIts is the iterations of the cells of a row whose worker panicked
+const failedIts = -1
+
 // Plot x-y coordinate bounds supplied by the user for zooming
 type Endpoints struct {
 	xmin float64
@@ -52,42 +97,352 @@ type Endpoints struct {
 
 var (
 	t *template.Template
+
+	// command line flags
+	warmup     = flag.Bool("warmup", false, "compute and cache the default view at startup")
+	cacheSize  = flag.Int("cache", 32, "number of computed grids kept in the cache, 0 disables caching")
+	tileCache  = flag.Int("tilecache", 1024, "number of encoded map tiles kept in the cache, 0 disables caching")
+	stress     = flag.Bool("enable-stress", false, "serve the stress test endpoint "+patternStress)
+	noOptimize = flag.Bool("no-optimize", false, "iterate the main cardioid and period 2 bulb of the Mandelbrot set by default instead of taking them as members")
+	jobWorkers = flag.Int("jobworkers", 1, "number of poster jobs rendered at the same time")
+	htmlCells  = flag.Int("htmlcells", 250000, "largest number of cells of the HTML grid, larger plots need an image format")
+	selftest   = flag.Bool("selftest", false, "compare small renders with the reference iterations and exit, nonzero on a mismatch")
+	profiling  = flag.Bool("pprof", false, "serve the net/http/pprof profiling endpoints under /debug/pprof/")
+	streamPNG  = flag.Bool("streampng", false, "compute and encode the PNG plots and posters row by row, without the grid cache")
+	viewsFile  = flag.String("bookmarks", "bookmarks.json", "JSON file of the named views saved at "+patternBookmarks)
+	tlsCert    = flag.String("tlscert", "", "certificate file to serve HTTPS and HTTP/2 with -tlskey, plain HTTP when empty")
+	tlsKey     = flag.String("tlskey", "", "private key file of the -tlscert certificate")
+	capWarn    = flag.Float64("capwarn", .5, "fraction of the cells at the iteration cap above which a plot warns that maxiter may be too low, 0 disables")
+	configFile = flag.String("config", "", "JSON file of the default window, size, maxiter, palettes and limits")
+	rowsFlag   = flag.Int("rows", rows, "default number of rows of the plot")
+	colsFlag   = flag.Int("columns", columns, "default number of columns of the plot")
+	iterFlag   = flag.Int("maxiter", maxIterations, "default maximum iterations")
+	limitFlag  = flag.Int("iterlimit", iterLimit, "largest maxiter accepted from a request")
+	sizeFlag   = flag.Int("maxsize", maxSize, "largest width or height of a synchronous plot")
+	outFile    = flag.String("out", "", "render one plot to the file, in the format of its extension, and exit without serving")
+	cliXmin    = flag.String("xmin", "", "left edge of the -out plot, the fractal's default when empty")
+	cliXmax    = flag.String("xmax", "", "right edge of the -out plot")
+	cliYmin    = flag.String("ymin", "", "bottom edge of the -out plot")
+	cliYmax    = flag.String("ymax", "", "top edge of the -out plot")
+	cliWidth   = flag.Int("width", 0, "width of the -out plot, -columns when 0")
+	cliHeight  = flag.Int("height", 0, "height of the -out plot, -rows when 0")
+	cliQuery   = flag.String("query", "", "other plot parameters of the -out plot as a query string, such as fractal=julia&coloring=smooth")
+
+	grids *LRU[Params, *Grid] // computed grids keyed by their plot parameters
 )
 
-// init parses the html template file done only once
+// plotTemplate is the html template built into the program, used when the
+// template file cannot be read from the working directory
+//
+//go:embed templates/plotdata.html
+var plotTemplate string
+
+// init parses the html template file done only once.  The file is read at its
+// relative address so it can be edited without rebuilding; if it is missing or
+// does not parse, the built-in copy is used instead.
 func init() {
-	t = template.Must(template.ParseFiles(tmpl))
+	var err error
+	if t, err = template.ParseFiles(tmpl); err != nil {
+		fmt.Printf("error: html template: %v, using the built-in template (run the server from src/mandelbrot to use the file)\n", err)
+		t = template.Must(template.New("plotdata.html").Parse(plotTemplate))
+	}
+}
+
+// Plot parameters resolved from the request
+type Params struct {
+	ep         Endpoints  // plot window in the complex plane
+	rows       int        // #rows in grid, multiplied by ssaa
+	columns    int        // #columns in grid, multiplied by ssaa
+	fractal    string     // registered fractal name
+	julia      bool       // the cell is z(0) and c is the Julia constant
+	c          complex128 // Julia constant
+	z0         complex128 // initial z of the Mandelbrot type fractals
+	zstartc    bool       // the Mandelbrot type fractals start from z(0) = c instead of z0
+	fixed      bool       // iterate in fixed point for bit-identical results on every platform
+	adaptive   bool       // refine a coarse grid only where the cells differ instead of computing every cell
+	bulbs      bool       // the cells in the main cardioid and period 2 bulb are members without iterating
+	mirror     bool       // the Julia cells are point reflections of the cells at -z on a centered window
+	power      int        // Multibrot exponent
+	single     bool       // iterate in float32 (complex64) arithmetic
+	pinned     bool       // the pin is kept at the center cell across zooms
+	pin        complex128 // pinned point in the complex plane
+	coloring   string     // coloring of the cells, iterations, smooth, relief, potential, angle, edge, binary, firstentry, components or lut
+	maxiter    int        // maximum iterations requested
+	radius     float64    // escape radius
+	iterations int        // iteration cap for maxiter and the escape radius
+	cutoff     float64    // fraction of the iteration cap a cell survives to be a member, 0 for all of it
+	diffmax    int        // maxiter of the grid the cells are compared with, 0 for none
+	overlay    string     // fractal blended over the plot in the same window, empty for none
+	blend      float64    // opacity of the overlay from 0 to 1
+	palette    string     // registered palette name
+	rotation   complex128 // unit rotation of the window about its center
+	projection string     // plane, or logpolar for the angle across and the log radius down about the center
+	ssaa       int        // supersampled cells per pixel in each direction
+	aapattern  string     // subsample positions in the pixel, grid, jitter or stratified
+	seed       int        // seed of the pseudo random subsample positions
+	downscale  int        // cells of the computed grid per returned cell in each direction
+	poolmax    bool       // downscale to the block maximum instead of the mean
+	dpr        float64    // device pixels per CSS pixel the rows and columns are scaled by
+	light      complex128 // unit vector of the relief light direction
+	height     float64    // height of the relief light above the plane
+	highlight  bool       // paint the cells with iterations from hlo to hhi
+	hlo        int        // lowest highlighted iterations
+	hhi        int        // highest highlighted iterations
+	hcolor     color.RGBA // color of the highlighted cells
+	legend     bool       // draw the color legend under the PNG plot
+	annotate   bool       // stamp the window, zoom and maxiter on the PNG plot
+	paletted   bool       // encode the PNG with an 8-bit palette instead of truecolor
+	clearset   bool       // the members of the set are transparent
+	labelfmt   string     // axis label notation, auto, fixed or sci
+	circle     bool       // paint the pixels outside the inscribed circle the mask color
+	vignette   float64    // strength of the fade of the edges to the mask color, 0 for none
+	mcolor     color.RGBA // background color of the mask and vignette
+	axes       bool       // draw the real and imaginary axes where they cross the window
+	acolor     color.RGBA // color of the axes
+	insetwin   Endpoints  // window of the magnified inset, zero for none
+	insetsize  float64    // width of the inset as a fraction of the plot width
+	insetpos   string     // corner of the inset, tl, tr, bl or br
+	icolor     color.RGBA // color of the inset frame and of the outline of its window
+	bgtint     float64    // strength of the position tint of the escaped cells, 0 for none
+	colormin   int        // iterations of the first palette color, -1 for the grid minimum
+	colormax   int        // iterations of the last palette color, -1 for the grid maximum
+	brightness float64    // shift of the normalized cell value, 0 is none
+	contrast   float64    // scale of the normalized cell value about the middle, 1 is none
+	gamma      float64    // gamma correction of the normalized cell value, 1 is none
+	logscale   bool       // spread log(1 + iterations) over the palette
+	colormod   int        // color the iterations modulo colormod over the palette, 0 for none
+	channel    string     // r, g or b to encode the iterations in that channel alone, empty for the palette
+	trap       complex128 // center of the disk of the firstentry coloring
+	trapr      float64    // radius of the disk of the firstentry coloring
+	lut        string     // maxiter + 1 comma separated rrggbb colors of the lut coloring, by iterations
+	jsonvalue  string     // raw, normalized or smooth value of the cells in the JSON plot
+	includez   bool       // send the final z of the escaped cells in the JSON plot
+	workaxis   string     // row or column, the cells computed by each goroutine
+	slowesc    string     // flag or recheck the cells at maxiter without a cycle, empty for none
+	fullw      int        // columns of the full image of a tile, 0 for a plot that is not a tile
+	fullh      int        // rows of the full image of a tile
+	offx       int        // column of the full image at the left of the tile
+	offy       int        // row of the full image at the top of the tile
+}
+
+// keepOrbit is true if the coloring needs the final z and its derivative of every cell
+func (p *Params) keepOrbit() bool {
+	return p.coloring == "relief" || p.coloring == "smooth" || p.coloring == "potential" || p.coloring == "angle" ||
+		p.coloring == "firstentry" || p.jsonvalue == "smooth" || p.includez
 }
 
-// determineSet determines which cells are in the Mandelbrot set by
-// squaring the point and requiring it to remain bounded for maxIterations.
-// Return the number of iterations done before escaping the bounds.
-func determineSet(row int, col int, ep *Endpoints) int {
+// iterationCap is the number of iterations for the maximum iterations and escape
+// radius.  An orbit that has just passed |z| = 2 grows roughly as |z|^d per
+// iteration (d is the power of the fractal), so reaching an escape radius R takes
+// about log_d(ln R / ln 2) more iterations.  They are added to maxiter so that set
+// membership does not depend on the escape radius and the fractional iterations of
+// the smooth coloring are not cut off for the slowly escaping cells.
+func iterationCap(maxiter int, radius float64, d int) int {
+	if radius <= 2 {
+		return maxiter
+	}
+	return maxiter + int(math.Ceil(math.Log(math.Log(radius)/math.Ln2)/math.Log(float64(d))))
+}
 
-	x := float64(col)/float64(columns-1)*(ep.xmax-ep.xmin) + ep.xmin
-	y := ep.ymax - float64(row)/float64(rows-1)*(ep.ymax-ep.ymin)
-	z := complex(x, y) // initial value
+// degree is the power d of the fractal's iteration, z(n+1) ~ z(n)^d
+func (p *Params) degree() int {
+	if p.fractal == "multibrot" {
+		return p.power
+	}
+	return 2
+}
 
-	var v complex128
-	for n := 0; n < maxIterations; n++ {
-		v = v*v + z
-		if cmplx.Abs(v) > 2 {
-			return n
+// cellPoint maps the cell to its point in the complex plane.  A rotated window
+// is turned about its center, so the corners sample slightly outside the endpoints.
+// On a window centered at the origin the second half of the cells are the exact
+// negations of the first, for the point symmetry of the Julia sets.  The cells of
+// a tile are mapped by their position in the full image.
+func cellPoint(row int, col int, p *Params) complex128 {
+	jitter := p.ssaa > 1 && p.aapattern != "grid"
+	if !jitter && p.projection == "plane" && p.fullw == 0 && p.ep.xmin == -p.ep.xmax && p.ep.ymin == -p.ep.ymax {
+		if mrow, mcol := p.rows-1-row, p.columns-1-col; row > mrow || (row == mrow && col > mcol) {
+			return -cellPoint(mrow, mcol, p)
 		}
 	}
-	return maxIterations
+	columns, rows := p.fullSize()
+	row, col = row+p.offy, col+p.offx
+	fx, fy := cellFraction(col, columns), cellFraction(row, rows)
+	if jitter {
+		dx, dy := p.subsample(row, col)
+		fx += dx / float64(columns-1)
+		fy += dy / float64(rows-1)
+	}
+	return planePoint(fx, fy, p)
+}
+
+// planePoint is the point of the complex plane at fractions fx across and fy down
+// the window, rotated about its center
+func planePoint(fx, fy float64, p *Params) complex128 {
+	ep := &p.ep
+	if p.projection == "logpolar" {
+		theta, r := logPolar(fx, fy, p)
+		center := complex((ep.xmin+ep.xmax)/2, (ep.ymin+ep.ymax)/2)
+		return center + cmplx.Rect(r, theta)*p.rotation
+	}
+	x := axisValue(fx, ep.xmin, ep.xmax)
+	y := axisValue(fy, ep.ymax, ep.ymin)
+	if p.rotation == 1 {
+		return complex(x, y)
+	}
+	center := complex((ep.xmin+ep.xmax)/2, (ep.ymin+ep.ymax)/2)
+	return center + (complex(x, y)-center)*p.rotation
+}
+
+// logPolar is the angle and radius about the center of the window at fractions fx
+// across and fy down the log-polar plot.  The angle goes once around from 0 at the
+// left, and the radius falls from half the diagonal of the window at the top by the
+// same scale per cell, so the shapes keep their proportions and a zoom by a factor
+// is a shift down the plot.
+func logPolar(fx, fy float64, p *Params) (float64, float64) {
+	ep := &p.ep
+	rmax := math.Hypot(ep.xmax-ep.xmin, ep.ymax-ep.ymin) / 2
+	columns, rows := p.fullSize()
+	decay := 2 * math.Pi * float64(rows) / float64(columns)
+	return 2 * math.Pi * fx, rmax * math.Exp(-decay*fy)
+}
+
+// axisValue is the coordinate at fraction f of the way from lo to hi, shared by
+// the cells and the axis labels so the labels are the coordinates that are plotted
+func axisValue(f, lo, hi float64) float64 {
+	return lo + f*(hi-lo)
+}
+
+// cellFraction is the position of cell i of n across the window, from 0 at the
+// first cell to 1 at the last.  A single cell samples the middle of the window.
+func cellFraction(i, n int) float64 {
+	if n == 1 {
+		return .5
+	}
+	return float64(i) / float64(n-1)
+}
+
+// subsample is the offset of the supersampled cell from its regular grid position,
+// in cells.  The stratified pattern moves each sample randomly within its own
+// cell, the jitter pattern anywhere within the pixel.
+func (p *Params) subsample(row, col int) (float64, float64) {
+	dx := randomOffset(p.seed, row, col, 0)
+	dy := randomOffset(p.seed, row, col, 1)
+	if p.aapattern == "stratified" {
+		return dx, dy
+	}
+	n := float64(p.ssaa)
+	center := (n - 1) / 2 // of the pixel, from its first cell
+	return center - float64(col%p.ssaa) + dx*n, center - float64(row%p.ssaa) + dy*n
+}
+
+// randomOffset is a reproducible pseudo random number from -.5 to .5 for the axis
+// of the cell, the splitmix64 hash of the seed and the cell.  It does not depend
+// on the order the cells are computed in.
+func randomOffset(seed, row, col, axis int) float64 {
+	h := uint64(seed) ^ uint64(row)<<33 ^ uint64(col)<<1 ^ uint64(axis)
+	h += 0x9e3779b97f4a7c15
+	h = (h ^ h>>30) * 0xbf58476d1ce4e5b9
+	h = (h ^ h>>27) * 0x94d049bb133111eb
+	h ^= h >> 31
+	return float64(h>>11)/(1<<53) - .5
+}
+
+// determineSet determines which cells are in the fractal set by iterating
+// the point and requiring it to remain bounded for the iteration cap.
+// Return the number of iterations done before escaping the bounds, the final z
+// and, for the relief coloring, the derivative of z with respect to the cell.  For
+// the firstentry coloring the real part of the derivative is instead the iteration
+// at which the orbit first entered the trap disk, -1 if it never did.
+func determineSet(row int, col int, p *Params, it Iterator) (int, complex128, complex128) {
+	if p.fixed {
+		return iterateFixed(row, col, p)
+	}
+	return iteratePoint(cellPoint(row, col, p), p, it)
 }
 
-// processRow determines which cells in the row are in the Mandelbrot set
-func processRow(row int, result chan<- Result, ep *Endpoints) {
-	// Loop over the columns (cells) and find those that satisfy Mandelbrot
+// iteratePoint is determineSet for the point z of the complex plane
+func iteratePoint(z complex128, p *Params, it Iterator) (int, complex128, complex128) {
+	// The Mandelbrot type fractals iterate from z0 (zero by default) with the cell
+	// as the constant, the Julia sets iterate from the cell with a fixed constant.
+	v := p.z0
+	var dv complex128   // derivative of v
+	dc := complex(1, 0) // derivative of the constant
+	if p.zstartc {
+		v, dv = z, 1
+	}
+	if p.julia {
+		v, z = z, p.c
+		dv, dc = 1, 0
+	}
+	if p.bulbs && inMainBulbs(z) {
+		return p.iterations, 0, 0
+	}
+	trap := p.coloring == "firstentry"
+	if trap {
+		dv = -1
+	}
+	// A cell that survives the interior cutoff is taken to be a member without
+	// iterating to the cap
+	limit := p.iterations
+	if p.cutoff > 0 {
+		limit = int(math.Ceil(p.cutoff * float64(p.iterations)))
+	}
+	if it32, ok := it.(Iterator32); ok && p.single && !trap {
+		n, v := iterate32(complex64(v), complex64(z), it32, limit)
+		if n == limit {
+			n = p.iterations
+		}
+		return n, complex128(v), 0
+	}
+	d, track := it.(Differentiator)
+	track = track && p.coloring == "relief"
+	for n := 0; n < limit; n++ {
+		if track {
+			dv = d.Derivative(v, dv) + dc
+		}
+		v = it.Next(v, z)
+		if trap && real(dv) < 0 && cmplx.Abs(v-p.trap) <= p.trapr {
+			dv = complex(float64(n), 0)
+		}
+		if it.Escaped(v) {
+			return n, v, dv
+		}
+	}
+	return p.iterations, v, dv
+}
+
+// iterate32 is the single precision iteration of determineSet.  It is faster and
+// uses half the memory bandwidth but loses accuracy sooner when zooming.
+func iterate32(v, z complex64, it Iterator32, iterations int) (int, complex64) {
+	for n := 0; n < iterations; n++ {
+		v = it.Next32(v, z)
+		if it.Escaped32(v) {
+			return n, v
+		}
+	}
+	return iterations, v
+}
+
+// processRow determines which cells in the row are in the fractal set
+func processRow(row int, result chan<- Result, p *Params, it Iterator) {
+	// Loop over the columns (cells) and find those that satisfy the fractal
 	// The number of iterations to escape is returned.
-	res := Result{}
-	res.its = make([]int, columns)
+	defer recoverRow(row, result, p)
+	begin := time.Now()
+	res := Result{minits: p.iterations}
+	res.its = make([]int, p.columns)
 	res.row = row
+	if p.keepOrbit() {
+		res.z = make([]complex128, p.columns)
+		res.dz = make([]complex128, p.columns)
+	}
 
-	for col := 0; col < columns; col++ {
-		its := determineSet(row, col, ep)
+	for col := 0; col < p.columns; col++ {
+		its, z, dz := determineSet(row, col, p, it)
+		if res.z != nil {
+			res.z[col] = z
+			res.dz[col] = dz
+		}
 		if its > res.maxits {
 			res.maxits = its
 		}
@@ -98,34 +453,888 @@ func processRow(row int, result chan<- Result, ep *Endpoints) {
 	}
 
 	// Send the result back
+	res.ms = ms(time.Since(begin))
 	result <- res
 }
 
-// handlePlotting receives the complex plane endpoints to inspect and plots the
-// the Mandelbrot iteration results.
-func handlePlotting(w http.ResponseWriter, r *http.Request) {
-	start := time.Now()
-	fmt.Printf("Start Time: %v\n", start.Format(time.RFC850))
-	var (
-		plot      PlotT
-		xmax      float64 = .8 // default endpoints in complex plane
-		xmin      float64 = -1.6
-		ymax      float64 = 1.2
-		ymin      float64 = -1.2
-		endpoints Endpoints
-	)
+// recoverRow is deferred by the row workers.  A panic while computing the row is
+// logged and the row is sent with its cells failedIts, so one bad row does not
+// take the server down.
+func recoverRow(row int, result chan<- Result, p *Params) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	fmt.Printf("error: row %d panicked: %v\n%s", row, r, debug.Stack())
+	result <- failedResult(row, p.columns, p)
+}
+
+// failedResult is the result of n cells whose worker panicked
+func failedResult(row, n int, p *Params) Result {
+	res := Result{row: row, its: make([]int, n), minits: p.iterations, failed: true}
+	for i := range res.its {
+		res.its[i] = failedIts
+	}
+	if p.keepOrbit() {
+		res.z = make([]complex128, n)
+		res.dz = make([]complex128, n)
+	}
+	return res
+}
 
-	plot.Grid = make([]string, rows*columns)
-	plot.Xlabel = make([]string, xlabels)
-	plot.Ylabel = make([]string, ylabels)
+// Grid holds the iteration results computed for the plot window.  It is the
+// output of the compute core and is independent of the presentation format.
+type Grid struct {
+	its    []int        // cell iterations in row-major order
+	z      []complex128 // final z of the cells if the coloring needs them
+	dz     []complex128 // derivative of the final z, or the first entry iteration of the trap
+	layer  *Grid        // grid of the overlay fractal, nil for none
+	inset  *Grid        // grid of the inset window, nil for none
+	rowms  []float64    // compute milliseconds of each row, nil if not computed by rows
+	failed bool         // a worker panicked and some cells are failedIts
+	slow   *SlowEscape  // outcome of the slow escape pass, nil for none
+	minits int          // minimum iteration over the grid
+	maxits int          // maximum iteration over the grid
+	p      *Params
+}
 
-	// channel for receiving results from goroutines
-	result := make(chan Result)
+// iterationWarning suggests a higher maxiter if more than -capwarn of the cells
+// reached the iteration cap:  slowly escaping cells near the boundary are then
+// likely counted as members and their detail is lost.  It is empty otherwise,
+// and for no grid or a difference grid.
+func iterationWarning(grid *Grid) string {
+	if grid == nil || grid.p.diffmax > 0 || *capWarn <= 0 || grid.maxits < grid.p.iterations {
+		return ""
+	}
+	members := 0
+	for _, its := range grid.its {
+		if its == grid.p.iterations {
+			members++
+		}
+	}
+	f := float64(members) / float64(len(grid.its))
+	if f <= *capWarn {
+		return ""
+	}
+	return fmt.Sprintf("%.0f%% of the cells reached maxiter %d, the plot may be under-iterated, try a higher maxiter",
+		100*f, grid.p.maxiter)
+}
+
+// parseParams reads the fractal and its options from the request form or JSON
+// body.  The defaults are used for values that are missing or invalid, the
+// invalid values are returned as errors.
+func parseParams(form Form) (*Params, ParamErrors) {
+	var errs ParamErrors
+	p := Params{rows: rows, columns: columns, fractal: "mandelbrot", c: complex(-.8, .156), power: 3,
+		coloring: "iterations", palette: "gray", labelfmt: "auto", maxiter: maxIterations, radius: radius, rotation: 1, projection: "plane", workaxis: "row", ssaa: 1, aapattern: "grid", downscale: 1, dpr: 1,
+		colormin: -1, colormax: -1, contrast: 1, gamma: 1}
+
+	if name := form.Get("fractal"); len(name) > 0 {
+		if _, ok := fractals[name]; ok {
+			p.fractal = name
+		} else {
+			errs.add(ErrUnknownValue, "fractal", "unknown fractal %q.", name)
+		}
+	}
+
+	// Sizes above maxSize are accepted here for the poster jobs, the plot handler
+	// rejects them
+	if width := form.Get("width"); len(width) > 0 {
+		n, err := strconv.Atoi(width)
+		if err != nil || n < 1 || n > maxPoster {
+			errs.add(numberCode(err), "width", "width %q is not an integer from 1 to %d.", width, maxPoster)
+		} else {
+			p.columns = n
+		}
+	}
+	if height := form.Get("height"); len(height) > 0 {
+		n, err := strconv.Atoi(height)
+		if err != nil || n < 1 || n > maxPoster {
+			errs.add(numberCode(err), "height", "height %q is not an integer from 1 to %d.", height, maxPoster)
+		} else {
+			p.rows = n
+		}
+	}
+
+	// High density displays get dpr times the cells in each direction, which the
+	// HTML page shows at 1/dpr the size
+	if dpr := form.Get("dpr"); len(dpr) > 0 {
+		v, err := strconv.ParseFloat(dpr, 64)
+		if err != nil || !(v >= 1 && v <= maxDPR) {
+			errs.add(numberCode(err), "dpr", "dpr %q is not a number from 1 to %v.", dpr, maxDPR)
+		} else {
+			p.dpr = v
+			p.rows = int(math.Round(float64(p.rows) * v))
+			p.columns = int(math.Round(float64(p.columns) * v))
+		}
+	}
+
+	creal := form.Get("creal")
+	cimag := form.Get("cimag")
+	if len(creal) > 0 && len(cimag) > 0 {
+		cr, err1 := strconv.ParseFloat(creal, 64)
+		ci, err2 := strconv.ParseFloat(cimag, 64)
+		if err1 != nil {
+			errs.add(ErrNotNumber, "creal", "c real %q is not a number.", creal)
+		} else if err2 != nil {
+			errs.add(ErrNotNumber, "cimag", "c imaginary %q is not a number.", cimag)
+		} else {
+			p.c = complex(cr, ci)
+		}
+	}
+
+	if power := form.Get("power"); len(power) > 0 {
+		d, err := strconv.Atoi(power)
+		if err != nil || d < 2 {
+			errs.add(numberCode(err), "power", "power %q is not an integer of at least 2.", power)
+		} else {
+			p.power = d
+		}
+	}
+
+	switch precision := form.Get("precision"); precision {
+	case "", "float64":
+	case "float32":
+		p.single = true
+	default:
+		errs.add(ErrUnknownValue, "precision", "precision %q is not float32 or float64.", precision)
+	}
+
+	if maxiter := form.Get("maxiter"); len(maxiter) > 0 {
+		n, err := strconv.Atoi(maxiter)
+		if err != nil || n < 1 || n > iterLimit {
+			errs.add(numberCode(err), "maxiter", "maxiter %q is not an integer from 1 to %d.", maxiter, iterLimit)
+		} else {
+			p.maxiter = n
+		}
+	}
+	if rs := form.Get("radius"); len(rs) > 0 {
+		rad, err := strconv.ParseFloat(rs, 64)
+		if err != nil || !(rad >= 2 && rad <= maxRadius) {
+			errs.add(numberCode(err), "radius", "escape radius %q is not a number from 2 to %v.", rs, maxRadius)
+		} else {
+			p.radius = rad
+		}
+	}
+	p.iterations = iterationCap(p.maxiter, p.radius, p.degree())
+	if cutoff := form.Get("interiorcutoff"); len(cutoff) > 0 {
+		v, err := parseFinite(cutoff)
+		if err != nil || v <= 0 || v > 1 {
+			errs.add(numberCode(err), "interiorcutoff", "interior cutoff %q is not a number greater than 0 and at most 1.", cutoff)
+		} else if v < 1 {
+			p.cutoff = v
+		}
+	}
+
+	if palette := form.Get("palette"); len(palette) > 0 {
+		if _, ok := palettes[palette]; ok {
+			p.palette = palette
+		} else {
+			errs.add(ErrUnknownValue, "palette", "unknown palette %q.", palette)
+		}
+	}
+
+	// The highlight paints the cells in an iteration band and dims the others, a
+	// missing end of the band is open
+	hlo := form.Get("highlightlo")
+	hhi := form.Get("highlighthi")
+	if len(hlo) > 0 || len(hhi) > 0 {
+		lo, err1 := parseIntDefault(hlo, 0)
+		hi, err2 := parseIntDefault(hhi, p.iterations)
+		hcolor, err3 := parseHexColor(form.Get("highlightcolor"), highlightColor)
+		if err1 != nil {
+			errs.add(ErrNotNumber, "highlightlo", "highlight lo %q is not an integer.", hlo)
+		} else if err2 != nil {
+			errs.add(ErrNotNumber, "highlighthi", "highlight hi %q is not an integer.", hhi)
+		} else if err3 != nil {
+			errs.add(ErrUnknownValue, "highlightcolor", "highlight %v.", err3)
+		} else if lo > hi {
+			errs.add(ErrInvertedRange, "highlightlo", "highlight lo %d is above hi %d.", lo, hi)
+		} else {
+			p.highlight = true
+			p.hlo, p.hhi, p.hcolor = lo, hi, hcolor
+		}
+	}
+
+	// A fixed color range keeps the colors of the same features from changing as
+	// the window moves, an end of the range that is not given follows the grid
+	cmin := form.Get("colormin")
+	cmax := form.Get("colormax")
+	if len(cmin) > 0 || len(cmax) > 0 {
+		lo, err1 := parseIntDefault(cmin, -1)
+		hi, err2 := parseIntDefault(cmax, -1)
+		if err1 != nil || (len(cmin) > 0 && lo < 0) {
+			errs.add(numberCode(err1), "colormin", "colormin %q is not a nonnegative integer.", cmin)
+		} else if err2 != nil || (len(cmax) > 0 && hi < 0) {
+			errs.add(numberCode(err2), "colormax", "colormax %q is not a nonnegative integer.", cmax)
+		} else if lo >= 0 && hi >= 0 && lo >= hi {
+			errs.add(ErrInvertedRange, "colormin", "colormin %d is not below colormax %d.", lo, hi)
+		} else {
+			p.colormin, p.colormax = lo, hi
+		}
+	}
+
+	if brightness := form.Get("brightness"); len(brightness) > 0 {
+		v, err := parseFinite(brightness)
+		if err != nil || v < -1 || v > 1 {
+			errs.add(numberCode(err), "brightness", "brightness %q is not a number from -1 to 1.", brightness)
+		} else {
+			p.brightness = v
+		}
+	}
+	if contrast := form.Get("contrast"); len(contrast) > 0 {
+		v, err := parseFinite(contrast)
+		if err != nil || v < 0 || v > maxContrast {
+			errs.add(numberCode(err), "contrast", "contrast %q is not a number from 0 to %v.", contrast, maxContrast)
+		} else {
+			p.contrast = v
+		}
+	}
+	if gamma := form.Get("gamma"); len(gamma) > 0 {
+		v, err := parseFinite(gamma)
+		if err != nil || v <= 0 || v > maxGamma {
+			errs.add(numberCode(err), "gamma", "gamma %q is not a number above 0 and up to %v.", gamma, maxGamma)
+		} else {
+			p.gamma = v
+		}
+	}
+
+	switch scale := form.Get("colorscale"); scale {
+	case "", "linear":
+	case "log":
+		p.logscale = true
+	default:
+		errs.add(ErrUnknownValue, "colorscale", "color scale %q is not linear or log.", scale)
+	}
+
+	switch legend := form.Get("legend"); legend {
+	case "", "false":
+	case "true":
+		p.legend = true
+	default:
+		errs.add(ErrUnknownValue, "legend", "legend %q is not true or false.", legend)
+	}
+	switch annotate := form.Get("annotate"); annotate {
+	case "", "false":
+	case "true":
+		p.annotate = true
+	default:
+		errs.add(ErrUnknownValue, "annotate", "annotate %q is not true or false.", annotate)
+	}
+
+	switch mode := form.Get("pngmode"); mode {
+	case "", "truecolor":
+	case "palette":
+		p.paletted = true
+	default:
+		errs.add(ErrUnknownValue, "pngmode", "png mode %q is not palette or truecolor.", mode)
+	}
+
+	switch format := form.Get("labelformat"); format {
+	case "":
+	case "auto", "fixed", "sci":
+		p.labelfmt = format
+	default:
+		errs.add(ErrUnknownValue, "labelformat", "label format %q is not auto, fixed or sci.", format)
+	}
+
+	switch clear := form.Get("transparentset"); clear {
+	case "", "false":
+	case "true":
+		p.clearset = true
+	default:
+		errs.add(ErrUnknownValue, "transparentset", "transparentset %q is not true or false.", clear)
+	}
+
+	switch mask := form.Get("mask"); mask {
+	case "", "none":
+	case "circle":
+		p.circle = true
+	default:
+		errs.add(ErrUnknownValue, "mask", "mask %q is not circle or none.", mask)
+	}
+	if vignette := form.Get("vignette"); len(vignette) > 0 {
+		v, err := parseFinite(vignette)
+		if err != nil || v < 0 || v > 1 {
+			errs.add(numberCode(err), "vignette", "vignette %q is not a number from 0 to 1.", vignette)
+		} else {
+			p.vignette = v
+		}
+	}
+	if background := form.Get("background"); len(background) > 0 {
+		v, err := parseFinite(background)
+		if err != nil || v < 0 || v > 1 {
+			errs.add(numberCode(err), "background", "background %q is not a number from 0 to 1.", background)
+		} else {
+			p.bgtint = v
+		}
+	}
+	switch axes := form.Get("axes"); axes {
+	case "", "false":
+	case "true":
+		p.axes = true
+	default:
+		errs.add(ErrUnknownValue, "axes", "axes %q is not true or false.", axes)
+	}
+	if ac, err := parseHexColor(form.Get("axescolor"), axesColor); err != nil {
+		errs.add(ErrUnknownValue, "axescolor", "axes %v.", err)
+	} else if p.axes {
+		p.acolor = ac
+	}
+	if mc, err := parseHexColor(form.Get("maskcolor"), maskColor); err != nil {
+		errs.add(ErrUnknownValue, "maskcolor", "mask %v.", err)
+	} else if p.circle || p.vignette > 0 {
+		p.mcolor = mc
+	}
+
+	f := fractals[p.fractal]
+	p.julia = f.julia
+
+	// The relief coloring shades the cells with a light at an angle (degrees from
+	// the positive real axis) and height over the plane.
+	switch coloring := form.Get("coloring"); coloring {
+	case "", "iterations":
+	case "smooth", "potential", "angle", "edge", "binary", "components":
+		p.coloring = coloring
+	case "relief":
+		if _, ok := f.iterator(&p).(Differentiator); !ok {
+			errs.add(ErrNotApplicable, "coloring", "relief coloring is not available for the %s fractal.", p.fractal)
+			break
+		}
+		angle, err1 := parseFloatDefault(form.Get("lightangle"), 45)
+		height, err2 := parseFloatDefault(form.Get("lightheight"), 1.5)
+		if err1 != nil {
+			errs.add(ErrNotNumber, "lightangle", "light angle %q is not a number of degrees.", form.Get("lightangle"))
+			break
+		}
+		if err2 != nil || height < 0 {
+			errs.add(numberCode(err2), "lightheight", "light height %q is not a nonnegative number.", form.Get("lightheight"))
+			break
+		}
+		p.coloring = coloring
+		p.light = cmplx.Rect(1, angle*math.Pi/180)
+		p.height = height
+	case "lut":
+		// The lut coloring colors the cells with the client's color for their
+		// iterations, from 0 to maxiter
+		lut := form.Get("lut")
+		colors := strings.Split(lut, ",")
+		if len(colors) != p.maxiter+1 {
+			errs.add(ErrOutOfRange, "lut", "lut has %d colors, not maxiter + 1 = %d.", len(colors), p.maxiter+1)
+			break
+		}
+		bad := false
+		for _, c := range colors {
+			if _, err := parseHexColor(c, color.RGBA{}); err != nil || len(c) == 0 {
+				errs.add(ErrUnknownValue, "lut", "lut color %q is not rrggbb hexadecimal.", c)
+				bad = true
+				break
+			}
+		}
+		if !bad {
+			p.coloring = coloring
+			p.lut = lut
+		}
+	case "firstentry":
+		// The firstentry coloring colors the cells by the iteration at which the
+		// orbit first comes within the trap radius of the trap point
+		x, err1 := parseFloatDefault(form.Get("trapx"), 0)
+		y, err2 := parseFloatDefault(form.Get("trapy"), 0)
+		r, err3 := parseFloatDefault(form.Get("trapradius"), .25)
+		if err1 != nil {
+			errs.add(ErrNotNumber, "trapx", "trap x %q is not a number.", form.Get("trapx"))
+			break
+		}
+		if err2 != nil {
+			errs.add(ErrNotNumber, "trapy", "trap y %q is not a number.", form.Get("trapy"))
+			break
+		}
+		if err3 != nil || r <= 0 {
+			errs.add(numberCode(err3), "trapradius", "trap radius %q is not a positive number.", form.Get("trapradius"))
+			break
+		}
+		p.coloring = coloring
+		p.trap = complex(x, y)
+		p.trapr = r
+	default:
+		errs.add(ErrUnknownValue, "coloring", "unknown coloring %q.", coloring)
+	}
+
+	// The difference grid holds the change of the iterations between maxiter and
+	// diffmaxiter, which shows where the boundary sharpens with more iterations
+	if diff := form.Get("diffmaxiter"); len(diff) > 0 {
+		n, err := strconv.Atoi(diff)
+		if err != nil || n < 1 || n > iterLimit || n == p.maxiter {
+			errs.add(numberCode(err), "diffmaxiter", "diffmaxiter %q is not an integer from 1 to %d other than maxiter.", diff, iterLimit)
+		} else if p.coloring != "iterations" {
+			errs.add(ErrNotApplicable, "diffmaxiter", "diffmaxiter does not apply to the %s coloring.", p.coloring)
+		} else {
+			p.diffmax = n
+		}
+	}
+
+	// The JSON plot sends the raw iterations of the cells, their position in the
+	// color range or their fractional escape iterations
+	p.jsonvalue = "raw"
+	switch jsonvalue := form.Get("jsonvalue"); jsonvalue {
+	case "", "raw":
+	case "normalized":
+		p.jsonvalue = jsonvalue
+	case "smooth":
+		if p.diffmax > 0 {
+			errs.add(ErrNotApplicable, "jsonvalue", "jsonvalue=smooth does not apply with diffmaxiter.")
+		} else {
+			p.jsonvalue = jsonvalue
+		}
+	default:
+		errs.add(ErrUnknownValue, "jsonvalue", "jsonvalue %q is not raw, normalized or smooth.", jsonvalue)
+	}
+	switch includez := form.Get("includez"); includez {
+	case "", "false":
+	case "true":
+		if p.diffmax > 0 {
+			errs.add(ErrNotApplicable, "includez", "includez does not apply with diffmaxiter.")
+		} else {
+			p.includez = true
+		}
+	default:
+		errs.add(ErrUnknownValue, "includez", "includez %q is not true or false.", includez)
+	}
+
+	// The iterations modulo colormod repeat the palette in periodic bands
+	if mod := form.Get("colormod"); len(mod) > 0 {
+		n, err := strconv.Atoi(mod)
+		if err != nil || n < 2 || n > iterLimit {
+			errs.add(numberCode(err), "colormod", "colormod %q is not an integer from 2 to %d.", mod, iterLimit)
+		} else if p.coloring != "iterations" {
+			errs.add(ErrNotApplicable, "colormod", "colormod does not apply to the %s coloring.", p.coloring)
+		} else {
+			p.colormod = n
+		}
+	}
+
+	// The overlay draws a second fractal sharing c over the plot, such as the Julia
+	// set of c over the Mandelbrot set
+	if overlay := form.Get("overlay"); len(overlay) > 0 {
+		if _, ok := fractals[overlay]; !ok || overlay == p.fractal {
+			errs.add(ErrUnknownValue, "overlay", "overlay %q is not a fractal other than %s.", overlay, p.fractal)
+		} else if p.diffmax > 0 {
+			errs.add(ErrNotApplicable, "overlay", "overlay does not apply with diffmaxiter.")
+		} else {
+			p.overlay = overlay
+			p.blend = .5
+		}
+	}
+	if blend := form.Get("blend"); len(blend) > 0 && len(p.overlay) > 0 {
+		v, err := parseFinite(blend)
+		if err != nil || v < 0 || v > 1 {
+			errs.add(numberCode(err), "blend", "blend %q is not a number from 0 to 1.", blend)
+		} else {
+			p.blend = v
+		}
+	}
 
-	xstart := r.FormValue("xstart")
-	xend := r.FormValue("xend")
-	ystart := r.FormValue("ystart")
-	yend := r.FormValue("yend")
+	// The inset magnifies a window, typically within the plot, in a corner
+	if inset := form.Get("inset"); len(inset) > 0 {
+		var w [4]float64
+		parts := strings.Split(inset, ",")
+		ok := len(parts) == 4
+		for i := 0; ok && i < 4; i++ {
+			var err error
+			w[i], err = parseFinite(strings.TrimSpace(parts[i]))
+			ok = err == nil
+		}
+		if !ok {
+			errs.add(ErrNotNumber, "inset", "inset %q is not four numbers xmin,xmax,ymin,ymax.", inset)
+		} else if w[0] >= w[1] || w[2] >= w[3] {
+			errs.add(ErrInvertedRange, "inset", "inset %q is not xmin,xmax,ymin,ymax with the minimums below the maximums.", inset)
+		} else {
+			p.insetwin = Endpoints{w[0], w[1], w[2], w[3]}
+		}
+	}
+	p.insetsize = 1.0 / 3
+	if size := form.Get("insetsize"); len(size) > 0 {
+		v, err := parseFinite(size)
+		if err != nil || v <= 0 || v > 1 {
+			errs.add(numberCode(err), "insetsize", "insetsize %q is not a number above 0 and at most 1.", size)
+		} else {
+			p.insetsize = v
+		}
+	}
+	switch pos := form.Get("insetpos"); pos {
+	case "":
+		p.insetpos = "br"
+	case "tl", "tr", "bl", "br":
+		p.insetpos = pos
+	default:
+		errs.add(ErrUnknownValue, "insetpos", "insetpos %q is not tl, tr, bl or br.", pos)
+	}
+	if ic, err := parseHexColor(form.Get("insetcolor"), insetColor); err != nil {
+		errs.add(ErrUnknownValue, "insetcolor", "inset %v.", err)
+	} else {
+		p.icolor = ic
+	}
+	if p.insetwin == (Endpoints{}) {
+		p.insetsize, p.insetpos, p.icolor = 0, "", color.RGBA{}
+	}
+
+	// The channel coloring packs the iterations into one color channel for
+	// compositing several renders into one image
+	switch channel := form.Get("channel"); channel {
+	case "":
+	case "r", "g", "b":
+		if p.coloring != "iterations" {
+			errs.add(ErrNotApplicable, "channel", "channel does not apply to the %s coloring.", p.coloring)
+		} else {
+			p.channel = channel
+		}
+	default:
+		errs.add(ErrUnknownValue, "channel", "channel %q is not r, g or b.", channel)
+	}
+
+	// A nonzero z0 blends the Julia set of each cell into the Mandelbrot type fractal.
+	// The Julia sets already start from the cell so z0 does not apply to them.
+	z0real := form.Get("z0real")
+	z0imag := form.Get("z0imag")
+	if len(z0real) > 0 || len(z0imag) > 0 {
+		zr, err1 := parseFloatDefault(z0real, 0)
+		zi, err2 := parseFloatDefault(z0imag, 0)
+		if err1 != nil {
+			errs.add(ErrNotNumber, "z0real", "z0 real %q is not a number.", z0real)
+		} else if err2 != nil {
+			errs.add(ErrNotNumber, "z0imag", "z0 imaginary %q is not a number.", z0imag)
+		} else if p.julia {
+			errs.add(ErrNotApplicable, "z0real", "z0 does not apply to the %s fractal which starts from the cell.", p.fractal)
+		} else {
+			p.z0 = complex(zr, zi)
+		}
+	}
+	// Starting from z(0) = c is the same orbit one iteration later than from zero,
+	// so the escaping cells take one iteration less
+	switch zstart := form.Get("zstart"); zstart {
+	case "", "zero":
+	case "c":
+		if p.julia {
+			errs.add(ErrNotApplicable, "zstart", "zstart does not apply to the %s fractal which starts from the cell.", p.fractal)
+		} else if p.z0 != 0 {
+			errs.add(ErrNotApplicable, "zstart", "zstart=c does not apply with a z0 of its own.")
+		} else {
+			p.zstartc = true
+		}
+	default:
+		errs.add(ErrUnknownValue, "zstart", "zstart %q is not zero or c.", zstart)
+	}
+	p.ep = parseEndpoints(form, f.endpoints, &errs)
+	p.ep = zoomEndpoints(form, p.ep, f.endpoints, &errs)
+
+	if rotate := form.Get("rotate"); len(rotate) > 0 {
+		deg, err := strconv.ParseFloat(rotate, 64)
+		if err != nil || math.IsInf(deg, 0) || math.IsNaN(deg) {
+			errs.add(ErrNotNumber, "rotate", "rotate %q is not a number of degrees.", rotate)
+		} else if math.Mod(deg, 360) != 0 {
+			p.rotation = cmplx.Rect(1, deg*math.Pi/180)
+		}
+	}
+	switch projection := form.Get("projection"); projection {
+	case "", "plane":
+	case "logpolar":
+		p.projection = projection
+	default:
+		errs.add(ErrUnknownValue, "projection", "projection %q is not plane or logpolar.", projection)
+	}
+	p.ep = selectEndpoints(form, &p, f.endpoints, &errs)
+
+	pinx := form.Get("pinx")
+	piny := form.Get("piny")
+	if len(pinx) > 0 && len(piny) > 0 {
+		x, err1 := parseFinite(pinx)
+		y, err2 := parseFinite(piny)
+		def := f.endpoints
+		if err1 != nil {
+			errs.add(ErrNotNumber, "pinx", "pin x %q is not a number.", pinx)
+		} else if err2 != nil {
+			errs.add(ErrNotNumber, "piny", "pin y %q is not a number.", piny)
+		} else if x <= def.xmin || x >= def.xmax {
+			errs.add(ErrXRange, "pinx", "pin (%v,%v) is not inside the default window.", x, y)
+		} else if y <= def.ymin || y >= def.ymax {
+			errs.add(ErrYRange, "piny", "pin (%v,%v) is not inside the default window.", x, y)
+		} else {
+			p.pinned = true
+			p.pin = complex(x, y)
+			p.ep = pinEndpoints(p.ep, p.pin, def, p.rows, p.columns)
+		}
+	}
+
+	// Supersampling computes ssaa x ssaa cells for each pixel of the plot, so the
+	// compute cost is multiplied by ssaa squared.
+	if ssaa := form.Get("ssaa"); len(ssaa) > 0 {
+		n, err := strconv.Atoi(ssaa)
+		if err != nil || n < 1 || n > maxSSAA {
+			errs.add(numberCode(err), "ssaa", "ssaa %q is not an integer from 1 to %d.", ssaa, maxSSAA)
+		} else {
+			p.ssaa = n
+		}
+	}
+	switch pattern := form.Get("aapattern"); pattern {
+	case "":
+	case "grid", "jitter", "stratified":
+		p.aapattern = pattern
+	default:
+		errs.add(ErrUnknownValue, "aapattern", "aa pattern %q is not grid, jitter or stratified.", pattern)
+	}
+	if seed := form.Get("seed"); len(seed) > 0 {
+		if n, err := strconv.Atoi(seed); err != nil {
+			errs.add(ErrNotNumber, "seed", "seed %q is not an integer.", seed)
+		} else {
+			p.seed = n
+		}
+	}
+	// Downscaling computes the full grid and returns blocks of it pooled into one
+	// cell, a low resolution overview without a separate coarse computation
+	if downscale := form.Get("downscale"); len(downscale) > 0 {
+		n, err := strconv.Atoi(downscale)
+		if err != nil || n < 1 || n > maxDownscale {
+			errs.add(numberCode(err), "downscale", "downscale %q is not an integer from 1 to %d.", downscale, maxDownscale)
+		} else if n > 1 && p.ssaa > 1 {
+			errs.add(ErrNotApplicable, "downscale", "downscale does not apply to a supersampled plot.")
+		} else {
+			p.downscale = n
+		}
+	}
+	switch pool := form.Get("pool"); pool {
+	case "", "mean":
+	case "max":
+		p.poolmax = true
+	default:
+		errs.add(ErrUnknownValue, "pool", "pool %q is not mean or max.", pool)
+	}
+	switch fixedpoint := form.Get("fixedpoint"); fixedpoint {
+	case "", "false":
+	case "true":
+		p.fixed = fixedApplies(&p, &errs)
+	default:
+		errs.add(ErrUnknownValue, "fixedpoint", "fixedpoint %q is not true or false.", fixedpoint)
+	}
+	switch adaptive := form.Get("adaptive"); adaptive {
+	case "", "false":
+	case "true":
+		if p.keepOrbit() {
+			errs.add(ErrNotApplicable, "adaptive", "adaptive does not apply to the %s coloring.", p.coloring)
+		} else {
+			p.adaptive = true
+		}
+	default:
+		errs.add(ErrUnknownValue, "adaptive", "adaptive %q is not true or false.", adaptive)
+	}
+	switch workaxis := form.Get("workaxis"); workaxis {
+	case "", "row":
+	case "column":
+		if p.adaptive {
+			errs.add(ErrNotApplicable, "workaxis", "workaxis=column does not apply with adaptive, which computes by blocks.")
+		} else {
+			p.workaxis = workaxis
+		}
+	default:
+		errs.add(ErrUnknownValue, "workaxis", "workaxis %q is not row or column.", workaxis)
+	}
+	// The slow escape pass iterates the orbits in float64 from the start and needs
+	// the iterations alone
+	switch slow := form.Get("slowescape"); slow {
+	case "":
+	case "flag", "recheck":
+		switch {
+		case p.keepOrbit():
+			errs.add(ErrNotApplicable, "slowescape", "slowescape does not apply to the %s coloring.", p.coloring)
+		case p.diffmax > 0 || p.fixed || p.single || p.cutoff > 0:
+			errs.add(ErrNotApplicable, "slowescape", "slowescape does not apply with diffmaxiter, fixedpoint, precision=float32 or interiorcutoff.")
+		default:
+			p.slowesc = slow
+		}
+	default:
+		errs.add(ErrUnknownValue, "slowescape", "slowescape %q is not flag or recheck.", slow)
+	}
+	if recheck := form.Get("recheckiter"); len(recheck) > 0 && p.slowesc == "recheck" {
+		n, err := strconv.Atoi(recheck)
+		if err != nil || n <= p.maxiter || n > iterLimit {
+			errs.add(numberCode(err), "recheckiter", "recheckiter %q is not an integer above maxiter %d and at most %d.", recheck, p.maxiter, iterLimit)
+		} else {
+			p.iterations = iterationCap(n, p.radius, p.degree())
+		}
+	} else if p.slowesc == "recheck" {
+		n := 10 * p.maxiter
+		if n > iterLimit {
+			n = iterLimit
+		}
+		p.iterations = iterationCap(n, p.radius, p.degree())
+	}
+	// The shortcut applies to the orbits of z^2 + c from 0 and only without the
+	// final z, which is not computed
+	optimize := !*noOptimize
+	switch o := form.Get("optimize"); o {
+	case "":
+	case "true", "false":
+		optimize = o == "true"
+	default:
+		errs.add(ErrUnknownValue, "optimize", "optimize %q is not true or false.", o)
+	}
+	p.bulbs = optimize && p.fractal == "mandelbrot" && p.z0 == 0 && !p.zstartc && p.radius >= 2 && !p.keepOrbit()
+	// The derivative of the relief coloring changes sign with z, fixed point maps
+	// the cells by its own arithmetic and the log-polar cells are not reflected
+	p.mirror = optimize && p.fractal == "julia" && p.coloring != "relief" && !p.fixed && p.projection == "plane"
+	parseTile(form, &p, &errs)
+	p.rows *= p.ssaa
+	p.columns *= p.ssaa
+	p.fullw, p.fullh, p.offx, p.offy = p.fullw*p.ssaa, p.fullh*p.ssaa, p.offx*p.ssaa, p.offy*p.ssaa
+
+	return &p, errs
+}
+
+// parseIntDefault parses the form value, returning def if it is empty
+func parseIntDefault(value string, def int) (int, error) {
+	if len(value) == 0 {
+		return def, nil
+	}
+	return strconv.Atoi(value)
+}
+
+// parseFloatDefault parses the form value as a finite number, returning def if
+// it is empty
+func parseFloatDefault(value string, def float64) (float64, error) {
+	if len(value) == 0 {
+		return def, nil
+	}
+	return parseFinite(value)
+}
+
+// parseFinite parses the form value as a finite number, NaN and the infinities
+// are errors
+func parseFinite(value string) (float64, error) {
+	v, err := strconv.ParseFloat(value, 64)
+	if err == nil && (math.IsNaN(v) || math.IsInf(v, 0)) {
+		return 0, fmt.Errorf("%q is not a finite number", value)
+	}
+	return v, err
+}
+
+// pinEndpoints moves the window so the pinned point maps to the center cell,
+// shrinking the window if necessary to stay within the fractal's default endpoints.
+func pinEndpoints(ep Endpoints, pin complex128, def Endpoints, rows, columns int) Endpoints {
+	fx := cellFraction(columns/2, columns) // fraction of the width left of the center cell
+	fy := cellFraction(rows/2, rows)       // fraction of the height above the center cell
+	x, y := real(pin), imag(pin)
+
+	w := ep.xmax - ep.xmin
+	w = math.Min(w, (x-def.xmin)/fx)
+	w = math.Min(w, (def.xmax-x)/(1-fx))
+	h := ep.ymax - ep.ymin
+	h = math.Min(h, (def.ymax-y)/fy)
+	h = math.Min(h, (y-def.ymin)/(1-fy))
+
+	xmin := x - fx*w
+	ymax := y + fy*h
+	return Endpoints{xmin, xmin + w, ymax - h, ymax}
+}
+
+// zoomEndpoints shrinks (zoomin) or grows (zoomout) the window about its center
+// by the zoom factor.  A zoom out is clamped to the fractal's default endpoints.
+func zoomEndpoints(form Form, ep Endpoints, def Endpoints, errs *ParamErrors) Endpoints {
+	zoomin := len(form.Get("zoomin")) > 0
+	zoomout := len(form.Get("zoomout")) > 0
+	if zoomin == zoomout {
+		return ep
+	}
+
+	factor := zoomFactor
+	if zf := form.Get("zoomfactor"); len(zf) > 0 {
+		f, err := parseFinite(zf)
+		if err != nil || f <= 1 {
+			errs.add(numberCode(err), "zoomfactor", "zoom factor %q is not a number greater than 1.", zf)
+		} else {
+			factor = f
+		}
+	}
+	if zoomin {
+		factor = 1 / factor
+	}
+
+	xc := (ep.xmin + ep.xmax) / 2
+	yc := (ep.ymin + ep.ymax) / 2
+	dx := (ep.xmax - ep.xmin) / 2 * factor
+	dy := (ep.ymax - ep.ymin) / 2 * factor
+	ep = Endpoints{xc - dx, xc + dx, yc - dy, yc + dy}
+
+	if zoomout {
+		ep.xmin = math.Max(ep.xmin, def.xmin)
+		ep.xmax = math.Min(ep.xmax, def.xmax)
+		ep.ymin = math.Max(ep.ymin, def.ymin)
+		ep.ymax = math.Min(ep.ymax, def.ymax)
+	}
+	return ep
+}
+
+// selectEndpoints is the window of the selection box dragged over the plot of the
+// window ep, the corners selx1, sely1 and selx2, sely2 in pixels of the requested
+// plot size from its top left.  The pixels map to the cells as in cellPoint, and
+// the window of a rotated plot is moved so the rotated selection is plotted.
+// The window is clamped to the fractal's default endpoints.
+func selectEndpoints(form Form, p *Params, def Endpoints, errs *ParamErrors) Endpoints {
+	names := []string{"selx1", "sely1", "selx2", "sely2"}
+	var sel [4]float64
+	for _, name := range names {
+		if len(form.Get(name)) == 0 {
+			return p.ep
+		}
+	}
+	for i, name := range names {
+		v, err := parseFinite(form.Get(name))
+		if err != nil {
+			errs.add(ErrNotNumber, name, "selection %s %q is not a number.", name, form.Get(name))
+			return p.ep
+		}
+		sel[i] = v
+	}
+	if sel[0] == sel[2] || sel[1] == sel[3] {
+		errs.add(ErrInvertedRange, "selx1", "the selection is empty.")
+		return p.ep
+	}
+
+	// fraction of the window at the pixel coordinate, cell i is at pixel (i + .5)/dpr
+	fraction := func(v float64, n int) float64 {
+		if n == 1 {
+			return .5
+		}
+		return (v*p.dpr - .5) / float64(n-1)
+	}
+	ep := p.ep
+	x1 := ep.xmin + fraction(math.Min(sel[0], sel[2]), p.columns)*(ep.xmax-ep.xmin)
+	x2 := ep.xmin + fraction(math.Max(sel[0], sel[2]), p.columns)*(ep.xmax-ep.xmin)
+	y1 := ep.ymax - fraction(math.Max(sel[1], sel[3]), p.rows)*(ep.ymax-ep.ymin)
+	y2 := ep.ymax - fraction(math.Min(sel[1], sel[3]), p.rows)*(ep.ymax-ep.ymin)
+
+	center := complex((ep.xmin+ep.xmax)/2, (ep.ymin+ep.ymax)/2)
+	c := center + (complex((x1+x2)/2, (y1+y2)/2)-center)*p.rotation
+	dx, dy := (x2-x1)/2, (y2-y1)/2
+	return Endpoints{
+		math.Max(real(c)-dx, def.xmin), math.Min(real(c)+dx, def.xmax),
+		math.Max(imag(c)-dy, def.ymin), math.Min(imag(c)+dy, def.ymax),
+	}
+}
+
+// parseEndpoints reads the complex plane endpoints from the request form.  The
+// endpoints must lie within the fractal's default endpoints, which are returned
+// if the values are missing or invalid.
+func parseEndpoints(form Form, def Endpoints, errs *ParamErrors) Endpoints {
+	var (
+		xmax = def.xmax // default endpoints in complex plane
+		xmin = def.xmin
+		ymax = def.ymax
+		ymin = def.ymin
+	)
+
+	xstart := form.Get("xstart")
+	xend := form.Get("xend")
+	ystart := form.Get("ystart")
+	yend := form.Get("yend")
 	if len(xstart) > 0 && len(xend) > 0 &&
 		len(ystart) > 0 && len(yend) > 0 {
 		x1, err1 := strconv.ParseFloat(xstart, 64)
@@ -134,16 +1343,22 @@ func handlePlotting(w http.ResponseWriter, r *http.Request) {
 		y2, err4 := strconv.ParseFloat(yend, 64)
 
 		if err1 != nil || err2 != nil || err3 != nil || err4 != nil {
-			plot.Status = "x or y values are not numbers."
-			fmt.Printf("error: x start error = %v, x end error = %v\n", err1, err2)
-			fmt.Printf("error: y start error = %v, y end error = %v\n", err3, err4)
-		} else {
-			if (x1 < xmin || x1 > xmax) || (x2 < xmin || x2 > xmax) || (x1 >= x2) {
-				plot.Status = "values are not in x range."
-				fmt.Printf("error: start or end value not in x range.\n")
-			} else if (y1 < ymin || y1 > ymax) || (y2 < ymin || y2 > ymax) || (y1 >= y2) {
-				plot.Status = "values are not in y range."
-				fmt.Printf("error: start or end value not in y range.\n")
+			names := []string{"xstart", "xend", "ystart", "yend"}
+			for i, err := range []error{err1, err2, err3, err4} {
+				if err != nil {
+					errs.add(ErrNotNumber, names[i], "x or y values are not numbers.")
+					break
+				}
+			}
+		} else {
+			if (x1 < xmin || x1 > xmax) || (x2 < xmin || x2 > xmax) {
+				errs.add(ErrXRange, "xstart", "start or end value not in x range.")
+			} else if (y1 < ymin || y1 > ymax) || (y2 < ymin || y2 > ymax) {
+				errs.add(ErrYRange, "ystart", "start or end value not in y range.")
+			} else if x1 >= x2 {
+				errs.add(ErrInvertedRange, "xstart", "x start is not below x end.")
+			} else if y1 >= y2 {
+				errs.add(ErrInvertedRange, "ystart", "y start is not below y end.")
 			} else {
 				// Valid endpoints, replace the default min and max values
 				xmin = x1
@@ -154,72 +1369,348 @@ func handlePlotting(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	endpoints = Endpoints{xmin, xmax, ymin, ymax}
+	return Endpoints{xmin, xmax, ymin, ymax}
+}
+
+// computeGrid determines the fractal iterations of every cell in the window.
+func computeGrid(p *Params) *Grid {
+	if p.insetwin != (Endpoints{}) {
+		return insetGrid(p)
+	}
+	if p.diffmax > 0 {
+		return diffGrid(p)
+	}
+	if len(p.overlay) > 0 {
+		return overlayGrid(p)
+	}
+	if len(p.slowesc) > 0 {
+		return slowGrid(p)
+	}
+	if p.adaptive {
+		return adaptiveGrid(p)
+	}
+	if p.workaxis == "column" {
+		return columnGrid(p)
+	}
+	grid := Grid{p: p, minits: p.iterations}
+	grid.its = make([]int, p.rows*p.columns)
+	grid.rowms = make([]float64, p.rows)
+	if p.keepOrbit() {
+		grid.z = make([]complex128, p.rows*p.columns)
+		grid.dz = make([]complex128, p.rows*p.columns)
+	}
+
+	// iteration formula of the fractal
+	it := fractals[p.fractal].iterator(p)
+
+	// channel for receiving results from goroutines
+	result := make(chan Result)
 
-	for row := 0; row < rows; row++ {
+	// The second half of a point-symmetric window is mirrored from the first
+	computed := p.rows
+	if p.mirror && p.ep.xmin == -p.ep.xmax && p.ep.ymin == -p.ep.ymax {
+		computed = (p.rows + 1) / 2
+	}
+	for row := 0; row < computed; row++ {
 		// process each row in a goroutine
-		go processRow(row, result, &endpoints)
+		go processRow(row, result, p, it)
 	}
 
 	// Collect the results from the goroutines
-	maxits := 0
-	minits := maxIterations
-	for row := 0; row < rows; row++ {
+	for row := 0; row < p.rows; row++ {
+		if row == computed {
+			for mrow := computed; mrow < p.rows; mrow++ {
+				go mirrorRow(mrow, result, &grid, it)
+			}
+		}
 		result := <-result
-		if result.minits < minits {
-			minits = result.minits
+		if result.minits < grid.minits {
+			grid.minits = result.minits
 		}
-		if result.maxits > maxits {
-			maxits = result.maxits
+		if result.maxits > grid.maxits {
+			grid.maxits = result.maxits
 		}
 
 		// Save the iterations of all the cells in this row
-		for col := 0; col < columns; col++ {
-			plot.Grid[result.row*columns+col] = strconv.Itoa(result.its[col])
+		copy(grid.its[result.row*p.columns:], result.its)
+		grid.rowms[result.row] = result.ms
+		grid.failed = grid.failed || result.failed
+		if grid.z != nil {
+			copy(grid.z[result.row*p.columns:], result.z)
+			copy(grid.dz[result.row*p.columns:], result.dz)
 		}
 	}
 
-	// Map iterations to background color:  higher iterations are dark gray to black,
-	// lower iterations are white to lighter shades of gray.  Black denotes members
-	// of the set.
-	color := []string{"gray1", "gray2", "gray3", "gray4", "gray5"}
+	return &grid
+}
 
-	// scale for iterations to color
-	its2color := float64(len(color)-1) / float64(maxits-minits)
+// diffGrid computes the grid at maxiter and at diffmaxiter and returns the change
+// of the cell iterations where the classification changes, the cells that are
+// members of the set with the smaller cap but escape with the larger.  The cells
+// that escape in both, at the same iteration, or are members in both are 0.
+func diffGrid(p *Params) *Grid {
+	base := *p
+	base.diffmax = 0
+	other := base
+	other.maxiter = p.diffmax
+	other.iterations = iterationCap(other.maxiter, other.radius, other.degree())
+	g1, g2 := computeGrid(&base), computeGrid(&other)
 
-	// Set the background color for all the cells in the grid based on cell iteration
-	for i, its := range plot.Grid {
-		itn, err := strconv.Atoi(its)
-		if err != nil {
-			fmt.Printf("strconv iterations error for index %d:  %v\n", i, err)
-			// color this cell as not being in the set
-			itn = minits
+	grid := Grid{p: p, its: make([]int, len(g1.its)), failed: g1.failed || g2.failed}
+	for i := range grid.its {
+		if (g1.its[i] == g1.p.iterations) != (g2.its[i] == g2.p.iterations) {
+			grid.its[i] = abs(g2.its[i] - g1.its[i])
+		}
+		if grid.its[i] > grid.maxits {
+			grid.maxits = grid.its[i]
 		}
-		plot.Grid[i] = color[int(float64(itn-minits)*its2color+.5)]
 	}
+	return &grid
+}
+
+// overlayGrid computes the grid of the plot and the grid of the overlay fractal in
+// the same window, with the same c and colors.  The mask is applied once, after
+// the overlay is blended.
+func overlayGrid(p *Params) *Grid {
+	base := *p
+	base.overlay = ""
+	grid := computeGrid(&base)
+	grid.p = p
+	grid.layer = computeGrid(overlayParams(p))
+	return grid
+}
+
+// overlayParams are the parameters of the overlay grid of the plot, which has no
+// mask, axes or inset of its own
+func overlayParams(p *Params) *Params {
+	other := *p
+	other.overlay = ""
+	other.fractal = p.overlay
+	other.julia = fractals[p.overlay].julia
+	other.circle, other.vignette, other.axes = false, 0, false
+	other.insetwin = Endpoints{}
+	return &other
+}
+
+// renderGrid returns the cached grid for the parameters, computing it if necessary
+func renderGrid(p *Params) *Grid {
+	if grid, ok := grids.Get(*p); ok {
+		return grid
+	}
+	grid := computeGrid(p)
+	if p.downscale > 1 {
+		grid = downscaleGrid(grid)
+	}
+	// A grid with failed rows is computed again by the next request
+	if !grid.failed {
+		grids.Put(*p, grid)
+	}
+	return grid
+}
+
+// downscaleGrid pools each downscale x downscale block of cells, the partial
+// blocks at the right and bottom edges included, into one cell with the mean or
+// the maximum of their iterations.  The pooled cell keeps the final z of the
+// block's cell with the iterations closest to the pooled value.
+func downscaleGrid(grid *Grid) *Grid {
+	p := *grid.p
+	n := p.downscale
+	p.rows = (grid.p.rows + n - 1) / n
+	p.columns = (grid.p.columns + n - 1) / n
+	pooled := Grid{p: &p, its: make([]int, p.rows*p.columns), minits: grid.minits}
+	if grid.z != nil {
+		pooled.z = make([]complex128, len(pooled.its))
+		pooled.dz = make([]complex128, len(pooled.its))
+	}
+	for row := 0; row < p.rows; row++ {
+		for col := 0; col < p.columns; col++ {
+			var block []int // cell indexes of the block
+			for r := row * n; r < (row+1)*n && r < grid.p.rows; r++ {
+				for c := col * n; c < (col+1)*n && c < grid.p.columns; c++ {
+					block = append(block, r*grid.p.columns+c)
+				}
+			}
+			sum, hi := 0, 0
+			for _, k := range block {
+				sum += grid.its[k]
+				if grid.its[k] > hi {
+					hi = grid.its[k]
+				}
+			}
+			its := hi
+			if !p.poolmax {
+				its = int(math.Round(float64(sum) / float64(len(block))))
+			}
+			i := row*p.columns + col
+			pooled.its[i] = its
+			if its > pooled.maxits {
+				pooled.maxits = its
+			}
+			if pooled.z != nil {
+				closest := block[0]
+				for _, k := range block {
+					if abs(grid.its[k]-its) < abs(grid.its[closest]-its) {
+						closest = k
+					}
+				}
+				pooled.z[i], pooled.dz[i] = grid.z[closest], grid.dz[closest]
+			}
+		}
+	}
+	if grid.layer != nil {
+		pooled.layer = downscaleGrid(grid.layer)
+	}
+	if grid.inset != nil {
+		pooled.inset = downscaleGrid(grid.inset)
+	}
+	if grid.slow != nil {
+		slow := *grid.slow
+		slow.cells = nil
+		pooled.slow = &slow
+	}
+	return &pooled
+}
+
+// abs is the absolute value of an int
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// warmupCache computes the default view so the first request is served from the cache
+func warmupCache() {
+	start := time.Now()
+	p, _ := parseParams(url.Values{})
+	renderGrid(p)
+	fmt.Printf("Warmup time: %v\n", time.Since(start))
+}
 
-	// Construct x-axis labels
-	incr := (xmax - xmin) / (xlabels - 1)
-	x := xmin
-	// First label is empty for alignment purposes
-	for i := range plot.Xlabel {
-		plot.Xlabel[i] = fmt.Sprintf("%.2f", x)
-		x += incr
+// handlePlotting receives the complex plane endpoints to inspect and plots the
+// the fractal iteration results in the format negotiated with the client.
+func handlePlotting(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	fmt.Printf("Start Time: %v\n", start.Format(time.RFC850))
+
+	form, err := requestForm(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, &ParamError{Code: ErrBadRequest, Message: fmt.Sprintf("request parameters: %v", err)})
+		return
 	}
 
-	// Construct the y-axis labels
-	incr = (ymax - ymin) / (ylabels - 1)
-	y := ymin
-	for i := range plot.Ylabel {
-		plot.Ylabel[i] = fmt.Sprintf("%.2f", y)
-		y += incr
+	enc, ok := negotiate(r, form)
+	if !ok {
+		writeError(w, http.StatusBadRequest, &ParamError{Code: ErrUnknownFormat,
+			Message: fmt.Sprintf("unknown format %q", form.Get("format")), Param: "format"})
+		return
 	}
 
-	plot.Status = fmt.Sprintf("Status: Data plotted from (%v,%v) to (%v,%v)", xmin, ymin, xmax, ymax)
+	form, found := viewForm(form)
+	p, errs := parseParams(form)
+	if !found {
+		errs.add(ErrUnknownValue, "view", "unknown view %q.", form.Get("view"))
+	}
+	var budget time.Duration // time budget of the plot, none if zero
+	if maxtime := form.Get("maxtime"); len(maxtime) > 0 {
+		ms, err := strconv.Atoi(maxtime)
+		if err != nil || ms < 1 {
+			errs.add(numberCode(err), "maxtime", "maxtime %q is not a positive number of milliseconds.", maxtime)
+		} else {
+			budget = time.Duration(ms) * time.Millisecond
+		}
+	}
+	var timing bool // report the timing statistics
+	switch t := form.Get("timing"); t {
+	case "", "false":
+	case "true":
+		timing = true
+	default:
+		errs.add(ErrUnknownValue, "timing", "timing %q is not true or false.", t)
+	}
+	if debugRequested(r, form) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := writeDebug(w, form, p, enc, errs); err != nil {
+			fmt.Printf("error: write debug output: %v\n", err)
+		}
+		return
+	}
+	if limit := float64(maxSize*p.ssaa) * p.dpr; float64(p.rows) > limit || float64(p.columns) > limit {
+		msg := fmt.Sprintf("plots larger than %d x %d are rendered by a job, POST to %s", maxSize, maxSize, patternJobs)
+		if enc.browser() {
+			http.Error(w, msg, http.StatusBadRequest)
+		} else {
+			writeError(w, http.StatusBadRequest, &ParamError{Code: ErrTooLarge, Message: msg})
+		}
+		return
+	}
+	// Every cell of the HTML grid is an element, so a large grid can bring the
+	// browser down
+	if cells := (p.rows / p.ssaa) * (p.columns / p.ssaa); enc.browser() && cells > *htmlCells {
+		http.Error(w, fmt.Sprintf("the HTML grid of %d cells is larger than %d cells, use format=png for this plot",
+			cells, *htmlCells), http.StatusBadRequest)
+		return
+	}
+	// The browser plots with the defaults for the invalid values, API clients are
+	// told what was wrong
+	if len(errs) > 0 && !enc.browser() {
+		writeErrors(w, http.StatusBadRequest, errs)
+		return
+	}
+	if enc.browser() {
+		// The page lists the invalid parameters under the form
+		enc.write = func(w io.Writer, grid *Grid) error { return plotHTML(w, grid, errs) }
+	}
+	var grid *Grid
+	var stream *rowImage
+	var t Timing
+	begin := time.Now()
+	if *streamPNG && enc.contentType == encoders["png"].contentType && p.streamable() && budget == 0 {
+		// The rows are computed as they are encoded, there is no grid
+		stream = newRowImage(p)
+		enc.write = stream.write
+	} else {
+		_, t.Cached = grids.Get(*p)
+		if budget > 0 {
+			grid = budgetGrid(p, start, budget)
+		} else {
+			grid = renderGrid(p)
+		}
+		t.ComputeMs, t.RowsMs = ms(time.Since(begin)), grid.rowms
+	}
+	if timing {
+		w.Header().Set("Trailer", "Server-Timing")
+		if enc.timed != nil && grid != nil {
+			enc.write = enc.timed(&t)
+		}
+	}
 
-	// Write to HTTP using template and grid
-	if err := t.Execute(w, plot); err != nil {
-		log.Fatalf("Write to HTTP output using template with grid error: %v\n", err)
+	w.Header().Set("Content-Type", enc.contentType)
+	msg := iterationWarning(grid)
+	if enc.contentType == encoders["tiff"].contentType && grid != nil && grid.maxits > math.MaxUint16 {
+		if len(msg) > 0 {
+			msg += "; "
+		}
+		msg += fmt.Sprintf("the 16-bit TIFF saturates the iterations above %d, format=npy has the full counts", math.MaxUint16)
+	}
+	if len(msg) > 0 {
+		w.Header().Set("X-Warning", msg)
+	}
+	if grid != nil && grid.slow != nil {
+		w.Header().Set("X-Slow-Escape", grid.slow.String())
+	}
+	begin = time.Now()
+	if err := enc.write(w, grid); err != nil {
+		fmt.Printf("error: write %s output: %v\n", enc.contentType, err)
+	}
+	if timing {
+		encode := time.Since(begin)
+		if stream != nil {
+			// The bands were computed while encoding
+			t.ComputeMs, t.RowsMs = stream.computeMs, stream.rowms
+			encode -= time.Duration(stream.computeMs * float64(time.Millisecond))
+		}
+		w.Header().Set("Server-Timing", serverTiming(t, encode, time.Since(start)))
 	}
 	end := time.Now()
 	fmt.Printf("End Time: %v\n", end.Format(time.RFC850))
@@ -228,8 +1719,65 @@ func handlePlotting(w http.ResponseWriter, r *http.Request) {
 
 // executive program
 func main() {
+	flag.Parse()
+	if (*tlsCert == "") != (*tlsKey == "") {
+		log.Fatalf("TLS error: -tlscert and -tlskey must be given together\n")
+	}
+	if *selftest {
+		runSelfTest()
+	}
+	if err := configure(*configFile); err != nil {
+		log.Fatalf("Configuration error: %v\n", err)
+	}
+	grids = newLRU[Params, *Grid](*cacheSize)
+	if len(*outFile) > 0 {
+		if err := renderFile(*outFile); err != nil {
+			log.Fatalf("Render error: %v\n", err)
+		}
+		fmt.Printf("Plot written to %s\n", *outFile)
+		return
+	}
+	tiles = newLRU[TileKey, []byte](*tileCache)
+	jobs = newJobQueue(*jobWorkers)
+	var err error
+	if bookmarks, err = loadBookmarks(*viewsFile); err != nil {
+		log.Fatalf("Load bookmarks error: %v\n", err)
+	}
+
+	// Precompute the default view while the server starts
+	if *warmup {
+		go warmupCache()
+	}
+
 	// Setup http server with handler for reading form and plotting points
-	http.HandleFunc(pattern, handlePlotting)
-	// Setup http server with handler for generating data for testing
-	http.ListenAndServe(addr, nil)
+	mux := http.NewServeMux()
+	mux.HandleFunc(pattern, handlePlotting)
+	mux.HandleFunc(patternTile, handleTile)
+	mux.HandleFunc(patternSheet, handleSheet)
+	mux.HandleFunc(patternProgressive, handleProgressive)
+	mux.HandleFunc(patternArea, handleArea)
+	mux.HandleFunc(patternGIF, handleGIF)
+	mux.HandleFunc(patternCapabilities, handleCapabilities)
+	mux.HandleFunc(patternScanline, handleScanline)
+	mux.HandleFunc(patternContains, handleContains)
+	mux.HandleFunc(patternAngle, handleAngle)
+	mux.HandleFunc(patternJulia, handleJulia)
+	mux.HandleFunc(patternPreview, handlePalettePreview)
+	mux.HandleFunc(patternJobs, handleJobs)
+	mux.HandleFunc(patternJob, handleJob)
+	mux.HandleFunc(patternBookmarks, handleBookmarks)
+	if *stress {
+		mux.HandleFunc(patternStress, handleStress)
+	}
+	if *profiling {
+		registerProfiling(mux)
+	}
+	// Setup http server with handler for generating data for testing, over TLS
+	// with HTTP/2 negotiated by the standard library when a certificate is given
+	if *tlsCert != "" {
+		err = http.ListenAndServeTLS(addr, *tlsCert, *tlsKey, mux)
+	} else {
+		err = http.ListenAndServe(addr, mux)
+	}
+	log.Fatalf("Server error: %v\n", err)
 }
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"image/color"
+	"image/png"
+	"math"
+	"math/cmplx"
+	"net/http"
+	"testing"
+)
+
+// A width or height of 1 samples the middle of the window in that direction, and
+// no cell gets a NaN or infinite coordinate
+func TestSingleCellSize(t *testing.T) {
+	const window = "&xstart=-1.5&xend=0.5&ystart=-0.25&yend=0.75"
+	for _, size := range []string{
+		"width=1&height=1",
+		"width=1&height=7",
+		"width=9&height=1",
+		"width=1&height=1&ssaa=3",
+		"width=1&height=1&ssaa=2&aapattern=stratified",
+		"width=1&height=1&projection=logpolar",
+		"width=1&height=1&rotate=30",
+	} {
+		p := testParams(t, size+window)
+		for row := 0; row < p.rows; row++ {
+			for col := 0; col < p.columns; col++ {
+				if c := cellPoint(row, col, p); cmplx.IsNaN(c) || cmplx.IsInf(c) {
+					t.Fatalf("%s: cell (%d,%d) is at %v", size, row, col, c)
+				}
+			}
+		}
+		grid := computeGrid(p)
+		for i, its := range grid.its {
+			if its < 0 || its > p.iterations {
+				t.Fatalf("%s: cell %d took %d iterations", size, i, its)
+			}
+		}
+		for _, format := range []string{"json", "png", "html"} {
+			if w := serve(handlePlotting, pattern+"?format="+format+"&"+size+window); w.Code != http.StatusOK {
+				t.Errorf("%s format=%s: status %d: %s", size, format, w.Code, w.Body)
+			}
+		}
+	}
+
+	p := testParams(t, "width=1&height=1"+window)
+	if c := cellPoint(0, 0, p); math.Abs(real(c)+0.5) > 1e-15 || math.Abs(imag(c)-0.25) > 1e-15 {
+		t.Errorf("the single cell is at %v, want the middle of the window -0.5+0.25i", c)
+	}
+}
+
+// The cardioid and period-2 bulb test gives the iterations of the full iteration
+// on windows over the bulbs, their boundary and the rest of the set
+func TestBulbsMatchBruteForce(t *testing.T) {
+	for _, window := range []string{
+		"width=200&height=200&maxiter=1000",
+		"width=201&height=151&maxiter=3000&xstart=-1.3&xend=-0.7&ystart=-0.3&yend=0.3",
+		"width=160&height=120&maxiter=5000&xstart=0.2&xend=0.3&ystart=-0.05&yend=0.05",
+		"width=100&height=100&maxiter=500&ssaa=2",
+	} {
+		optimized, full := testParams(t, window), testParams(t, window+"&optimize=false")
+		if !optimized.bulbs || full.bulbs {
+			t.Fatalf("%s: the bulb test is %v optimized and %v not", window, optimized.bulbs, full.bulbs)
+		}
+		og, fg := computeGrid(optimized), computeGrid(full)
+		for i, its := range fg.its {
+			if og.its[i] != its {
+				t.Fatalf("%s: cell (%d,%d) took %d iterations optimized, %d not",
+					window, i/full.columns, i%full.columns, og.its[i], its)
+			}
+		}
+	}
+}
+
+// panicIterator is the Mandelbrot iteration that panics on the points of one row
+type panicIterator struct {
+	Mandelbrot
+	y float64 // imaginary part of the points of the row
+}
+
+func (it panicIterator) Next(z, c complex128) complex128 {
+	if imag(c) == it.y {
+		panic("injected panic")
+	}
+	return it.Mandelbrot.Next(z, c)
+}
+
+// A panic in a row worker is recovered by recoverRow: the row is sent with its
+// cells failed, the plot is served with the row in the fail color and the grid
+// is not cached
+func TestRowPanic(t *testing.T) {
+	const query = "width=20&height=10&xstart=-1.5&xend=0.5&ystart=-0.5&yend=0.8"
+	const bad = 6
+	p := testParams(t, query)
+	y := imag(cellPoint(bad, 0, p))
+	fractals["panicky"] = Fractal{
+		endpoints: fractals["mandelbrot"].endpoints,
+		iterator:  func(p *Params) Iterator { return panicIterator{Mandelbrot{newBailout(p.radius)}, y} },
+	}
+	defer delete(fractals, "panicky")
+
+	result := make(chan Result)
+	go processRow(bad, result, p, panicIterator{Mandelbrot{newBailout(p.radius)}, y})
+	res := <-result
+	if !res.failed || res.row != bad || len(res.its) != p.columns {
+		t.Fatalf("row %d: failed %v, %d cells", res.row, res.failed, len(res.its))
+	}
+	for col, its := range res.its {
+		if its != failedIts {
+			t.Errorf("cell (%d,%d) took %d iterations, want %d", bad, col, its, failedIts)
+		}
+	}
+
+	w := serve(handlePlotting, pattern+"?format=png&fractal=panicky&"+query)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	img, err := png.Decode(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for row := 0; row < p.rows; row++ {
+		if c := color.RGBAModel.Convert(img.At(3, row)); (c == failColor) != (row == bad) {
+			t.Errorf("row %d has the color %v", row, c)
+		}
+	}
+	if _, ok := grids.Get(*testParams(t, "fractal=panicky&"+query)); ok {
+		t.Errorf("the grid with the failed row is cached")
+	}
+}
+
+// alwaysPanic is the Mandelbrot iteration that panics on every point
+type alwaysPanic struct{ Mandelbrot }
+
+func (alwaysPanic) Next(z, c complex128) complex128 {
+	panic("injected panic")
+}
+
+// A tile whose rows panic is served but not cached, and an area estimate whose
+// points panic is an error
+func TestTileAndAreaPanic(t *testing.T) {
+	fractals["panicky"] = Fractal{
+		endpoints: fractals["mandelbrot"].endpoints,
+		iterator:  func(p *Params) Iterator { return alwaysPanic{Mandelbrot{newBailout(p.radius)}} },
+	}
+	defer delete(fractals, "panicky")
+
+	const tile = patternTile + "?z=1&x=0&y=0&fractal=panicky"
+	for i := 0; i < 2; i++ {
+		if w := serve(handleTile, tile); w.Code != http.StatusOK || w.Header().Get("X-Cache") != "MISS" {
+			t.Errorf("request %d: status %d, X-Cache %q", i+1, w.Code, w.Header().Get("X-Cache"))
+		}
+	}
+	if w := serve(handleArea, patternArea+"?fractal=panicky&samples=10000&seed=1"); w.Code != http.StatusInternalServerError {
+		t.Errorf("area: status %d: %s", w.Code, w.Body)
+	}
+}
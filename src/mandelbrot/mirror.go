@@ -0,0 +1,53 @@
+// Point symmetry of the Julia sets.  z^2 + c takes z and -z to the same point, so
+// the cells at z and -z have the same orbit after the first iteration and the
+// same iterations and final z.  On a window centered at the origin the second
+// half of the rows are the point reflections of the first half and are copied
+// instead of computed.  A cell is only copied when its coordinates are exactly
+// the negation of the reflected cell's, otherwise it is computed, so the grid is
+// the same as the full computation.
+
+package main
+
+import "time"
+
+// mirrorRow fills the row from the reflections of the computed rows of the grid,
+// computing the cells whose reflection is not exact
+func mirrorRow(row int, result chan<- Result, grid *Grid, it Iterator) {
+	defer recoverRow(row, result, grid.p)
+	begin := time.Now()
+	p := grid.p
+	res := Result{row: row, its: make([]int, p.columns), minits: p.iterations}
+	if grid.z != nil {
+		res.z = make([]complex128, p.columns)
+		res.dz = make([]complex128, p.columns)
+	}
+
+	mrow := p.rows - 1 - row
+	for col := 0; col < p.columns; col++ {
+		mcol := p.columns - 1 - col
+		var its int
+		var z, dz complex128
+		if cellPoint(row, col, p) == -cellPoint(mrow, mcol, p) {
+			i := mrow*p.columns + mcol
+			its = grid.its[i]
+			if grid.z != nil {
+				z, dz = grid.z[i], grid.dz[i]
+			}
+		} else {
+			its, z, dz = determineSet(row, col, p, it)
+		}
+		if res.z != nil {
+			res.z[col], res.dz[col] = z, dz
+		}
+		if its > res.maxits {
+			res.maxits = its
+		}
+		if its < res.minits {
+			res.minits = its
+		}
+		res.its[col] = its
+	}
+
+	res.ms = ms(time.Since(begin))
+	result <- res
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+// The mirrored rows of a point-symmetric Julia window, with an odd or even number
+// of rows and columns, rotated or with a c of its own, are the rows of the full
+// computation, and the PNG is the same byte for byte, as is the grid of a window
+// off center.
+func TestMirrorMatchesBruteForce(t *testing.T) {
+	const window = "&xstart=-1.5&xend=1.5&ystart=-1.2&yend=1.2"
+	for _, size := range []string{
+		"width=40&height=30" + window,
+		"width=41&height=31" + window,
+		"width=40&height=31&ssaa=2" + window,
+		"width=41&height=30&coloring=smooth" + window,
+		"width=60&height=44&coloring=smooth&rotate=30" + window,
+		"width=50&height=51&creal=-0.8&cimag=0.156" + window,
+		"width=40&height=40&xstart=-1.4&xend=1.5&ystart=-1.2&yend=1.2",
+	} {
+		q := "fractal=julia&maxiter=300&" + size
+		mirrored, full := testParams(t, q), testParams(t, q+"&optimize=false")
+		if !mirrored.mirror || full.mirror {
+			t.Fatalf("%s: mirror is %v with the optimization and %v without", size, mirrored.mirror, full.mirror)
+		}
+		mg, fg := computeGrid(mirrored), computeGrid(full)
+		for i := range fg.its {
+			if mg.its[i] != fg.its[i] {
+				t.Fatalf("%s: cell (%d,%d) took %d iterations mirrored, %d computed",
+					size, i/full.columns, i%full.columns, mg.its[i], fg.its[i])
+			}
+			if fg.z != nil && (mg.z[i] != fg.z[i] || mg.dz[i] != fg.dz[i]) {
+				t.Fatalf("%s: cell (%d,%d) ends at %v mirrored, %v computed",
+					size, i/full.columns, i%full.columns, mg.z[i], fg.z[i])
+			}
+		}
+		if mg.minits != fg.minits || mg.maxits != fg.maxits {
+			t.Errorf("%s: iterations %d to %d mirrored, %d to %d computed", size, mg.minits, mg.maxits, fg.minits, fg.maxits)
+		}
+		var mpng, fpng bytes.Buffer
+		if err := writePNG(&mpng, mg); err != nil {
+			t.Fatal(err)
+		}
+		if err := writePNG(&fpng, fg); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(mpng.Bytes(), fpng.Bytes()) {
+			t.Errorf("%s: the mirrored PNG differs from the computed one", size)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+// Palette previews for palette pickers.  /mandelbrot/palette-preview?palette=...
+// returns a thin horizontal strip of the palette as a PNG, from the color of the
+// fastest escaping cells at the left to the members of the set at the right.
+
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+const (
+	patternPreview = "/mandelbrot/palette-preview" // http handler pattern for palette previews
+	previewWidth   = 256                           // default width of the strip
+	previewHeight  = 16                            // default height of the strip
+)
+
+// handlePalettePreview sends the strip of the palette.  The gamma, contrast and
+// brightness apply as for the plot, and width and height set the strip size, up
+// to maxSize on a side.
+func handlePalettePreview(w http.ResponseWriter, r *http.Request) {
+	form, err := requestForm(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, &ParamError{Code: ErrBadRequest, Message: fmt.Sprintf("request parameters: %v", err)})
+		return
+	}
+	stripDefault := url.Values{}
+	stripDefault.Set("width", strconv.Itoa(previewWidth))
+	stripDefault.Set("height", strconv.Itoa(previewHeight))
+	p, errs := parseParams(forms{form, stripDefault})
+	if len(errs) > 0 {
+		writeErrors(w, http.StatusBadRequest, errs)
+		return
+	}
+
+	width, height := p.columns/p.ssaa, p.rows/p.ssaa
+	if width > maxSize || height > maxSize {
+		writeError(w, http.StatusBadRequest, &ParamError{Code: ErrTooLarge, Param: "width",
+			Message: fmt.Sprintf("palette previews larger than %d x %d are not rendered", maxSize, maxSize)})
+		return
+	}
+
+	palette := palettes[p.palette]
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		c := gradient(palette, p.adjust(cellFraction(x, width)))
+		for y := 0; y < height; y++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	if err := png.Encode(w, img); err != nil {
+		fmt.Printf("error: write palette preview: %v\n", err)
+	}
+}
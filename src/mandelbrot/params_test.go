@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+)
+
+// NaN and the infinities are invalid values of the number parameters
+func TestNonFiniteParams(t *testing.T) {
+	for _, tc := range []struct {
+		query, param string
+	}{
+		{"zoomin=1&zoomfactor=NaN", "zoomfactor"},
+		{"zoomout=1&zoomfactor=Inf", "zoomfactor"},
+		{"pinx=NaN&piny=0", "pinx"},
+		{"pinx=-0.5&piny=NaN", "piny"},
+		{"z0real=NaN", "z0real"},
+		{"z0real=0.1&z0imag=-Inf", "z0imag"},
+		{"coloring=relief&lightheight=NaN", "lightheight"},
+		{"coloring=relief&lightheight=Inf", "lightheight"},
+		{"coloring=relief&lightangle=NaN", "lightangle"},
+		{"radius=NaN", "radius"},
+		{"radius=1e200", "radius"},
+		{"dpr=NaN", "dpr"},
+		{"dpr=Inf", "dpr"},
+		{"brightness=NaN", "brightness"},
+		{"contrast=NaN", "contrast"},
+		{"gamma=NaN", "gamma"},
+		{"mask=circle&vignette=NaN", "vignette"},
+		{"background=NaN", "background"},
+		{"coloring=firstentry&trapradius=NaN", "trapradius"},
+		{"coloring=firstentry&trapradius=Inf", "trapradius"},
+		{"coloring=firstentry&trapx=NaN", "trapx"},
+		{"interiorcutoff=NaN", "interiorcutoff"},
+		{"overlay=julia&blend=NaN", "blend"},
+		{"inset=-1,0,-1,0&insetsize=NaN", "insetsize"},
+		{"selx1=NaN&sely1=10&selx2=100&sely2=200", "selx1"},
+		{"selx1=10&sely1=10&selx2=100&sely2=Inf", "sely2"},
+	} {
+		form, err := url.ParseQuery(tc.query)
+		if err != nil {
+			t.Fatalf("query %q: %v", tc.query, err)
+		}
+		_, errs := parseParams(form)
+		if _, ok := errs.fields()[tc.param]; !ok {
+			t.Errorf("%s: no error for %s, the errors are %v", tc.query, tc.param, errs.fields())
+		}
+	}
+}
@@ -0,0 +1,54 @@
+// PNG plots in a JSON envelope for format=json+png.  The image is sent as a data
+// URL with the size, window and iteration range of the plot, so a single page
+// front end can show the image and read its metadata in one round trip.
+
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+)
+
+// PlotPNGJSON is the PNG plot and its metadata as sent to json+png clients
+type PlotPNGJSON struct {
+	Width  int     `json:"width"` // of the image in pixels
+	Height int     `json:"height"`
+	Bounds Bounds  `json:"bounds"` // window of the plot
+	Minits int     `json:"minits"`
+	Maxits int     `json:"maxits"`
+	Image  string  `json:"image"`            // data:image/png;base64 URL of the plot as format=png sends it
+	Timing *Timing `json:"timing,omitempty"` // compute time with timing=true
+}
+
+// writePNGJSON sends the PNG plot in the JSON envelope
+func writePNGJSON(w io.Writer, grid *Grid) error {
+	return writeTimedPNGJSON(nil)(w, grid)
+}
+
+// writeTimedPNGJSON is writePNGJSON with the timing field
+func writeTimedPNGJSON(t *Timing) func(io.Writer, *Grid) error {
+	return func(w io.Writer, grid *Grid) error {
+		var buf bytes.Buffer
+		if err := writePNG(&buf, grid); err != nil {
+			return err
+		}
+		// The size is that of the encoded image, of the downscaled grid with the legend
+		p := grid.p
+		width, height := p.columns/p.ssaa, p.rows/p.ssaa
+		if p.legend && hasLegend(p) {
+			height += legendHeight
+		}
+		ep := p.ep
+		return json.NewEncoder(w).Encode(PlotPNGJSON{
+			Width:  width,
+			Height: height,
+			Bounds: Bounds{ep.xmin, ep.xmax, ep.ymin, ep.ymax},
+			Minits: grid.minits,
+			Maxits: grid.maxits,
+			Image:  "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()),
+			Timing: t,
+		})
+	}
+}
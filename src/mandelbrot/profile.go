@@ -0,0 +1,22 @@
+// Profiling endpoints of net/http/pprof for capturing CPU and memory profiles of
+// live renders, for example with
+// go tool pprof http://127.0.0.1:8080/debug/pprof/profile?seconds=30.
+// They are only served when the server is started with -pprof.
+
+package main
+
+import (
+	"net/http"
+	"net/http/pprof"
+)
+
+// registerProfiling serves the pprof handlers under /debug/pprof/.  The server has
+// its own mux, so the handlers net/http/pprof registers with the default mux
+// are not reachable without the flag.
+func registerProfiling(mux *http.ServeMux) {
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+}
@@ -0,0 +1,143 @@
+// Progressive refinement.  /mandelbrot/progressive renders the window in passes
+// of increasing resolution, from an eighth of the size up to the full plot, and
+// pushes every pass to the client as soon as it is done.  The PNG passes are a
+// multipart/x-mixed-replace stream, which a browser shows in place in an <img>;
+// format=json streams one JSON grid per line.
+
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+const (
+	patternProgressive = "/mandelbrot/progressive" // http handler pattern for progressive renders
+	refineStart        = 8                         // resolution divisor of the first pass
+)
+
+// refine renders the plot in passes, each with twice the resolution of the one
+// before, and emits every grid.  The last pass is the full plot from the grid
+// cache.  It stops at the first emit error.
+func refine(p *Params, emit func(*Grid) error) error {
+	for f := refineStart; f > 1; f /= 2 {
+		if err := emit(computeGrid(passParams(p, f))); err != nil {
+			return err
+		}
+	}
+	return emit(renderGrid(p))
+}
+
+// passParams are the parameters of the pass at 1/f the resolution of the plot,
+// without supersampling.  The full plot is the pass of f = 1.  A tile is the
+// tile of the full image at 1/f the resolution, at the offset scaled down.
+func passParams(p *Params, f int) *Params {
+	if f == 1 {
+		return p
+	}
+	pass := *p
+	pass.rows = (p.rows/p.ssaa + f - 1) / f
+	pass.columns = (p.columns/p.ssaa + f - 1) / f
+	pass.ssaa = 1
+	if p.fullw > 0 {
+		pass.fullw = (p.fullw/p.ssaa + f - 1) / f
+		pass.fullh = (p.fullh/p.ssaa + f - 1) / f
+		pass.offx = p.offx / p.ssaa / f
+		pass.offy = p.offy / p.ssaa / f
+	}
+	return &pass
+}
+
+// handleProgressive streams the passes of the plot to the client
+func handleProgressive(w http.ResponseWriter, r *http.Request) {
+	form, err := requestForm(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, &ParamError{Code: ErrBadRequest, Message: fmt.Sprintf("request parameters: %v", err)})
+		return
+	}
+	p, errs := parseParams(form)
+	if len(errs) > 0 {
+		writeErrors(w, http.StatusBadRequest, errs)
+		return
+	}
+	if limit := float64(maxSize*p.ssaa) * p.dpr; float64(p.rows) > limit || float64(p.columns) > limit {
+		writeError(w, http.StatusBadRequest, &ParamError{Code: ErrTooLarge,
+			Message: fmt.Sprintf("plots larger than %d x %d are rendered by a job, POST to %s", maxSize, maxSize, patternJobs)})
+		return
+	}
+	flusher, _ := w.(http.Flusher)
+	flush := func() {
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	// Every pass is scaled up to the plot size so the client draws it in place
+	width, height := p.columns/p.ssaa, p.rows/p.ssaa
+	var emit func(*Grid) error
+	var mw *multipart.Writer
+	switch format := form.Get("format"); format {
+	case "", "png":
+		mw = multipart.NewWriter(w)
+		w.Header().Set("Content-Type", "multipart/x-mixed-replace; boundary="+mw.Boundary())
+		emit = func(grid *Grid) error {
+			if err := r.Context().Err(); err != nil {
+				return err
+			}
+			img := plotImage(grid)
+			if img.Rect.Dx() != width || img.Rect.Dy() != height {
+				scaled := image.NewRGBA(image.Rect(0, 0, width, height))
+				xdraw.NearestNeighbor.Scale(scaled, scaled.Rect, img, img.Rect, xdraw.Src, nil)
+				img = scaled
+			}
+			if grid.p.legend && hasLegend(grid.p) {
+				img = drawLegend(img, grid)
+			}
+			part, err := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {"image/png"}})
+			if err != nil {
+				return err
+			}
+			if err := png.Encode(part, img); err != nil {
+				return err
+			}
+			flush()
+			return nil
+		}
+	case "json":
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		emit = func(grid *Grid) error {
+			if err := r.Context().Err(); err != nil {
+				return err
+			}
+			if err := writeJSON(w, grid); err != nil {
+				return err
+			}
+			flush()
+			return nil
+		}
+	default:
+		writeError(w, http.StatusBadRequest, &ParamError{Code: ErrUnknownFormat,
+			Message: fmt.Sprintf("format %q is not png or json", format), Param: "format"})
+		return
+	}
+
+	passes := 0
+	err = refine(p, func(grid *Grid) error {
+		passes++
+		return emit(grid)
+	})
+	if err != nil {
+		fmt.Printf("error: progressive render after %d passes: %v\n", passes, err)
+		return
+	}
+	if mw != nil {
+		mw.Close()
+	}
+	fmt.Printf("Progressive render: %d passes\n", passes)
+}
@@ -0,0 +1,182 @@
+// Plot parameters from JSON request bodies.  Programmatic clients can POST the
+// parameters as a JSON object with Content-Type: application/json instead of form
+// values.  Both are read through the Form interface so they share the validation
+// in parseParams.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Form is the source of the plot parameters, url.Values for the query and the
+// HTML form or a PlotRequest for a JSON body.  Get returns "" for a missing value.
+type Form interface {
+	Get(name string) string
+}
+
+// PlotRequest is the typed JSON body of the plot parameters.  Missing fields take
+// the same defaults as missing form values.
+type PlotRequest struct {
+	Format         string   `json:"format,omitempty"`
+	Fractal        string   `json:"fractal,omitempty"`
+	Width          *int     `json:"width,omitempty"`
+	Height         *int     `json:"height,omitempty"`
+	DPR            *float64 `json:"dpr,omitempty"`
+	Xstart         *float64 `json:"xstart,omitempty"`
+	Xend           *float64 `json:"xend,omitempty"`
+	Ystart         *float64 `json:"ystart,omitempty"`
+	Yend           *float64 `json:"yend,omitempty"`
+	Zoomin         bool     `json:"zoomin,omitempty"`
+	Zoomout        bool     `json:"zoomout,omitempty"`
+	Zoomfactor     *float64 `json:"zoomfactor,omitempty"`
+	Selx1          *float64 `json:"selx1,omitempty"`
+	Sely1          *float64 `json:"sely1,omitempty"`
+	Selx2          *float64 `json:"selx2,omitempty"`
+	Sely2          *float64 `json:"sely2,omitempty"`
+	Pinx           *float64 `json:"pinx,omitempty"`
+	Piny           *float64 `json:"piny,omitempty"`
+	Creal          *float64 `json:"creal,omitempty"`
+	Cimag          *float64 `json:"cimag,omitempty"`
+	Power          *int     `json:"power,omitempty"`
+	Z0real         *float64 `json:"z0real,omitempty"`
+	Z0imag         *float64 `json:"z0imag,omitempty"`
+	Zstart         string   `json:"zstart,omitempty"`
+	Precision      string   `json:"precision,omitempty"`
+	Maxiter        *int     `json:"maxiter,omitempty"`
+	Diffmaxiter    *int     `json:"diffmaxiter,omitempty"`
+	Radius         *float64 `json:"radius,omitempty"`
+	Palette        string   `json:"palette,omitempty"`
+	Coloring       string   `json:"coloring,omitempty"`
+	Lightangle     *float64 `json:"lightangle,omitempty"`
+	Lightheight    *float64 `json:"lightheight,omitempty"`
+	Rotate         *float64 `json:"rotate,omitempty"`
+	Projection     string   `json:"projection,omitempty"`
+	SSAA           *int     `json:"ssaa,omitempty"`
+	AAPattern      string   `json:"aapattern,omitempty"`
+	Seed           *int     `json:"seed,omitempty"`
+	Downscale      *int     `json:"downscale,omitempty"`
+	Pool           string   `json:"pool,omitempty"`
+	Highlightlo    *int     `json:"highlightlo,omitempty"`
+	Highlighthi    *int     `json:"highlighthi,omitempty"`
+	Highlightcolor string   `json:"highlightcolor,omitempty"`
+	Legend         bool     `json:"legend,omitempty"`
+	Annotate       bool     `json:"annotate,omitempty"`
+	PNGMode        string   `json:"pngmode,omitempty"`
+	Mask           string   `json:"mask,omitempty"`
+	Vignette       *float64 `json:"vignette,omitempty"`
+	Maskcolor      string   `json:"maskcolor,omitempty"`
+	Background     *float64 `json:"background,omitempty"`
+	Trapx          *float64 `json:"trapx,omitempty"`
+	Trapy          *float64 `json:"trapy,omitempty"`
+	Trapradius     *float64 `json:"trapradius,omitempty"`
+	Interiorcutoff *float64 `json:"interiorcutoff,omitempty"`
+	Colormod       *int     `json:"colormod,omitempty"`
+	Channel        string   `json:"channel,omitempty"`
+	Fixedpoint     bool     `json:"fixedpoint,omitempty"`
+	Adaptive       bool     `json:"adaptive,omitempty"`
+	Workaxis       string   `json:"workaxis,omitempty"`
+	Slowescape     string   `json:"slowescape,omitempty"`
+	Fullwidth      *int     `json:"fullwidth,omitempty"`
+	Fullheight     *int     `json:"fullheight,omitempty"`
+	Tilewidth      *int     `json:"tilewidth,omitempty"`
+	Tileheight     *int     `json:"tileheight,omitempty"`
+	Offsetx        *int     `json:"offsetx,omitempty"`
+	Offsety        *int     `json:"offsety,omitempty"`
+	Recheckiter    *int     `json:"recheckiter,omitempty"`
+	Optimize       *bool    `json:"optimize,omitempty"`
+	Axes           bool     `json:"axes,omitempty"`
+	Axescolor      string   `json:"axescolor,omitempty"`
+	Smooth         bool     `json:"smooth,omitempty"`
+	Jsonvalue      string   `json:"jsonvalue,omitempty"`
+	Includez       bool     `json:"includez,omitempty"`
+	Inset          string   `json:"inset,omitempty"`
+	Insetsize      *float64 `json:"insetsize,omitempty"`
+	Insetpos       string   `json:"insetpos,omitempty"`
+	Insetcolor     string   `json:"insetcolor,omitempty"`
+	Transparentset bool     `json:"transparentset,omitempty"`
+	Labelformat    string   `json:"labelformat,omitempty"`
+	Overlay        string   `json:"overlay,omitempty"`
+	Blend          *float64 `json:"blend,omitempty"`
+	Lut            string   `json:"lut,omitempty"`
+	Colormin       *int     `json:"colormin,omitempty"`
+	Colormax       *int     `json:"colormax,omitempty"`
+	Brightness     *float64 `json:"brightness,omitempty"`
+	Contrast       *float64 `json:"contrast,omitempty"`
+	Gamma          *float64 `json:"gamma,omitempty"`
+	Colorscale     string   `json:"colorscale,omitempty"`
+	Debug          bool     `json:"debug,omitempty"`
+	Timing         bool     `json:"timing,omitempty"`
+	View           string   `json:"view,omitempty"`
+	Maxtime        *int     `json:"maxtime,omitempty"`
+	Frames         *int     `json:"frames,omitempty"`
+	Delay          *int     `json:"delay,omitempty"`
+	Framezoom      *float64 `json:"framezoom,omitempty"`
+	Targetx        *float64 `json:"targetx,omitempty"`
+	Targety        *float64 `json:"targety,omitempty"`
+	Row            *int     `json:"row,omitempty"`
+	X              *float64 `json:"x,omitempty"`
+	Y              *float64 `json:"y,omitempty"`
+	Samples        *int     `json:"samples,omitempty"`
+}
+
+// requestForm returns the decoded JSON body for a JSON request, otherwise the
+// parsed query and form values
+func requestForm(r *http.Request) (Form, error) {
+	if ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); ct == "application/json" {
+		var pr PlotRequest
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&pr); err != nil {
+			return nil, fmt.Errorf("JSON body: %v", err)
+		}
+		return &pr, nil
+	}
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+	return r.Form, nil
+}
+
+// Get formats the field with the JSON name as the equivalent form value.  Floats
+// are formatted with the fewest digits that parse back to the same value.
+func (pr *PlotRequest) Get(name string) string {
+	v := reflect.ValueOf(pr).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if strings.Split(v.Type().Field(i).Tag.Get("json"), ",")[0] != name {
+			continue
+		}
+		f := v.Field(i)
+		ptr := f.Kind() == reflect.Ptr
+		if ptr {
+			if f.IsNil() {
+				return ""
+			}
+			f = f.Elem()
+		}
+		switch f.Kind() {
+		case reflect.Float64:
+			return strconv.FormatFloat(f.Float(), 'g', -1, 64)
+		case reflect.Int:
+			return strconv.FormatInt(f.Int(), 10)
+		case reflect.Bool:
+			if f.Bool() {
+				return "true"
+			}
+			// Only a pointer tells false from missing
+			if ptr {
+				return "false"
+			}
+		case reflect.String:
+			return f.String()
+		}
+		return ""
+	}
+	return ""
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// The parameters of the endpoints are accepted in a JSON body
+func TestJSONParams(t *testing.T) {
+	for _, tc := range []struct {
+		handler http.HandlerFunc
+		target  string
+		body    string
+	}{
+		{handleGIF, patternGIF, `{"width":16,"height":12,"frames":2,"delay":5,"framezoom":1.5,"targetx":-0.5,"targety":0.1}`},
+		{handlePlotting, pattern, `{"format":"json","width":8,"height":8,"timing":true}`},
+		{handleArea, patternArea, `{"samples":5000,"seed":1}`},
+	} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, tc.target, strings.NewReader(tc.body))
+		r.Header.Set("Content-Type", "application/json")
+		tc.handler(w, r)
+		if w.Code != http.StatusOK {
+			t.Errorf("%s %s: status %d: %s", tc.target, tc.body, w.Code, w.Body)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+// Single row of the grid for pixel level debugging.  /mandelbrot/scanline?row=n
+// computes only that row of the requested plot and returns its iterations.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+const patternScanline = "/mandelbrot/scanline" // http handler pattern for a single row
+
+// ScanlineJSON is the computed row as sent to the client
+type ScanlineJSON struct {
+	Row        int     `json:"row"`
+	Y          float64 `json:"y"` // imaginary part of the row, before any rotation
+	Xmin       float64 `json:"xmin"`
+	Xmax       float64 `json:"xmax"`
+	Columns    int     `json:"columns"`
+	Minits     int     `json:"minits"`
+	Maxits     int     `json:"maxits"`
+	Iterations []int   `json:"iterations"` // cell iterations from left to right
+}
+
+// handleScanline computes the row of the plot with processRow and sends its
+// iterations as JSON.  The row is one of the grid rows, which are multiplied by
+// ssaa and dpr.
+func handleScanline(w http.ResponseWriter, r *http.Request) {
+	form, err := requestForm(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, &ParamError{Code: ErrBadRequest, Message: fmt.Sprintf("request parameters: %v", err)})
+		return
+	}
+	p, errs := parseParams(form)
+	if len(errs) > 0 {
+		writeErrors(w, http.StatusBadRequest, errs)
+		return
+	}
+	row, err := strconv.Atoi(form.Get("row"))
+	if err != nil || row < 0 || row >= p.rows {
+		writeError(w, http.StatusBadRequest, &ParamError{Code: numberCode(err),
+			Message: fmt.Sprintf("row %q is not an integer from 0 to %d", form.Get("row"), p.rows-1), Param: "row"})
+		return
+	}
+
+	result := make(chan Result, 1)
+	processRow(row, result, p, fractals[p.fractal].iterator(p))
+	res := <-result
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(ScanlineJSON{
+		Row:        row,
+		Y:          p.ep.ymax - cellFraction(row, p.rows)*(p.ep.ymax-p.ep.ymin),
+		Xmin:       p.ep.xmin,
+		Xmax:       p.ep.xmax,
+		Columns:    p.columns,
+		Minits:     res.minits,
+		Maxits:     res.maxits,
+		Iterations: res.its,
+	}); err != nil {
+		fmt.Printf("error: write scanline: %v\n", err)
+	}
+}
@@ -0,0 +1,251 @@
+// Startup self test of the compute core.  With -selftest the server computes a
+// few small windows, compares the iteration counts with the golden reference
+// below and exits, nonzero on any mismatch.  It guards the arithmetic against
+// regressions from optimizations of the iteration loops.
+
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+)
+
+// selfTestCase is a small plot and its reference iterations
+type selfTestCase struct {
+	name   string
+	query  string // plot parameters
+	golden []int  // row-major iterations
+}
+
+var selfTestCases = []selfTestCase{
+	{
+		name:  "mandelbrot",
+		query: "width=16&height=16&maxiter=100",
+		golden: []int{
+			1, 1, 1, 2, 2, 2, 2, 2, 2, 2, 2, 1, 1, 1, 1, 1,
+			1, 1, 2, 2, 2, 2, 2, 3, 4, 27, 5, 3, 2, 1, 1, 1,
+			2, 2, 2, 2, 2, 3, 3, 4, 5, 15, 7, 4, 3, 2, 1, 1,
+			2, 2, 2, 2, 3, 3, 4, 6, 8, 100, 14, 5, 5, 3, 2, 1,
+			2, 2, 2, 3, 4, 5, 7, 100, 100, 100, 100, 100, 100, 4, 2, 2,
+			2, 3, 8, 6, 6, 6, 47, 100, 100, 100, 100, 100, 100, 5, 2, 2,
+			3, 4, 7, 100, 100, 11, 100, 100, 100, 100, 100, 100, 100, 5, 3, 2,
+			5, 8, 17, 100, 100, 100, 100, 100, 100, 100, 100, 100, 100, 4, 3, 2,
+			5, 8, 17, 100, 100, 100, 100, 100, 100, 100, 100, 100, 100, 4, 3, 2,
+			3, 4, 7, 100, 100, 11, 100, 100, 100, 100, 100, 100, 100, 5, 3, 2,
+			2, 3, 8, 6, 6, 6, 47, 100, 100, 100, 100, 100, 100, 5, 2, 2,
+			2, 2, 2, 3, 4, 5, 7, 100, 100, 100, 100, 100, 100, 4, 2, 2,
+			2, 2, 2, 2, 3, 3, 4, 6, 8, 100, 14, 5, 5, 3, 2, 1,
+			2, 2, 2, 2, 2, 3, 3, 4, 5, 15, 7, 4, 3, 2, 1, 1,
+			1, 1, 2, 2, 2, 2, 2, 3, 4, 27, 5, 3, 2, 1, 1, 1,
+			1, 1, 1, 2, 2, 2, 2, 2, 2, 2, 2, 1, 1, 1, 1, 1,
+		},
+	},
+	{
+		name:  "mandelbrot float32",
+		query: "width=16&height=16&maxiter=100&precision=float32",
+		golden: []int{
+			1, 1, 1, 2, 2, 2, 2, 2, 2, 2, 2, 1, 1, 1, 1, 1,
+			1, 1, 2, 2, 2, 2, 2, 3, 4, 27, 5, 3, 2, 1, 1, 1,
+			2, 2, 2, 2, 2, 3, 3, 4, 5, 15, 7, 4, 3, 2, 1, 1,
+			2, 2, 2, 2, 3, 3, 4, 6, 8, 100, 14, 5, 5, 3, 2, 1,
+			2, 2, 2, 3, 4, 5, 7, 100, 100, 100, 100, 100, 100, 4, 2, 2,
+			2, 3, 8, 6, 6, 6, 47, 100, 100, 100, 100, 100, 100, 5, 2, 2,
+			3, 4, 7, 100, 100, 11, 100, 100, 100, 100, 100, 100, 100, 5, 3, 2,
+			5, 8, 17, 100, 100, 100, 100, 100, 100, 100, 100, 100, 100, 4, 3, 2,
+			5, 8, 17, 100, 100, 100, 100, 100, 100, 100, 100, 100, 100, 4, 3, 2,
+			3, 4, 7, 100, 100, 11, 100, 100, 100, 100, 100, 100, 100, 5, 3, 2,
+			2, 3, 8, 6, 6, 6, 47, 100, 100, 100, 100, 100, 100, 5, 2, 2,
+			2, 2, 2, 3, 4, 5, 7, 100, 100, 100, 100, 100, 100, 4, 2, 2,
+			2, 2, 2, 2, 3, 3, 4, 6, 8, 100, 14, 5, 5, 3, 2, 1,
+			2, 2, 2, 2, 2, 3, 3, 4, 5, 15, 7, 4, 3, 2, 1, 1,
+			1, 1, 2, 2, 2, 2, 2, 3, 4, 27, 5, 3, 2, 1, 1, 1,
+			1, 1, 1, 2, 2, 2, 2, 2, 2, 2, 2, 1, 1, 1, 1, 1,
+		},
+	},
+	{
+		name:  "seahorse valley",
+		query: "width=16&height=16&maxiter=500&xstart=-0.8&xend=-0.7&ystart=0.05&yend=0.15",
+		golden: []int{
+			500, 500, 27, 64, 19, 19, 19, 19, 45, 254, 500, 500, 500, 500, 500, 500,
+			500, 76, 42, 23, 20, 20, 20, 21, 24, 41, 500, 500, 500, 500, 500, 500,
+			500, 500, 500, 33, 22, 21, 21, 21, 50, 500, 500, 500, 500, 500, 500, 500,
+			500, 500, 500, 70, 24, 22, 22, 23, 27, 500, 500, 500, 500, 500, 500, 500,
+			500, 500, 500, 69, 122, 24, 23, 24, 134, 500, 500, 500, 500, 500, 500, 500,
+			500, 500, 500, 500, 220, 26, 25, 25, 154, 500, 500, 500, 500, 500, 500, 500,
+			500, 500, 500, 500, 500, 29, 26, 27, 55, 500, 500, 500, 500, 500, 500, 500,
+			500, 500, 500, 500, 500, 66, 28, 29, 188, 500, 500, 500, 500, 500, 500, 500,
+			500, 500, 500, 500, 500, 417, 30, 31, 420, 500, 500, 500, 500, 500, 500, 500,
+			500, 500, 500, 500, 500, 500, 34, 33, 147, 500, 500, 500, 500, 500, 500, 500,
+			500, 500, 500, 500, 500, 500, 40, 35, 72, 500, 500, 500, 500, 500, 500, 500,
+			500, 500, 500, 500, 500, 500, 75, 39, 500, 500, 500, 500, 500, 500, 500, 500,
+			500, 500, 500, 500, 500, 500, 500, 43, 500, 500, 500, 500, 500, 500, 500, 500,
+			500, 500, 500, 500, 500, 500, 500, 47, 500, 500, 500, 500, 500, 500, 500, 500,
+			500, 500, 500, 500, 500, 500, 500, 54, 500, 500, 500, 500, 500, 500, 500, 500,
+			500, 500, 500, 500, 500, 500, 500, 262, 500, 500, 500, 500, 500, 500, 500, 500,
+		},
+	},
+	{
+		name:  "julia",
+		query: "fractal=julia&width=16&height=16&maxiter=100",
+		golden: []int{
+			0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+			0, 0, 0, 0, 1, 1, 1, 1, 1, 1, 0, 0, 0, 0, 0, 0,
+			0, 0, 1, 1, 1, 1, 2, 2, 2, 1, 1, 1, 1, 0, 0, 0,
+			0, 1, 1, 1, 2, 2, 3, 100, 4, 2, 2, 1, 1, 1, 0, 0,
+			0, 1, 1, 2, 3, 4, 9, 73, 100, 4, 2, 2, 1, 1, 1, 0,
+			1, 2, 3, 100, 5, 26, 100, 100, 100, 22, 4, 3, 3, 1, 1, 0,
+			1, 4, 74, 100, 51, 100, 23, 77, 100, 13, 8, 100, 32, 3, 2, 1,
+			1, 56, 100, 38, 17, 99, 17, 40, 100, 12, 32, 100, 100, 5, 3, 1,
+			1, 3, 5, 100, 100, 32, 12, 100, 40, 17, 99, 17, 38, 100, 56, 1,
+			1, 2, 3, 32, 100, 8, 13, 100, 77, 23, 100, 51, 100, 74, 4, 1,
+			0, 1, 1, 3, 3, 4, 22, 100, 100, 100, 26, 5, 100, 3, 2, 1,
+			0, 1, 1, 1, 2, 2, 4, 100, 73, 9, 4, 3, 2, 1, 1, 0,
+			0, 0, 1, 1, 1, 2, 2, 4, 100, 3, 2, 2, 1, 1, 1, 0,
+			0, 0, 0, 1, 1, 1, 1, 2, 2, 2, 1, 1, 1, 1, 0, 0,
+			0, 0, 0, 0, 0, 0, 1, 1, 1, 1, 1, 1, 0, 0, 0, 0,
+			0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		},
+	},
+	{
+		name:  "burningship",
+		query: "fractal=burningship&width=16&height=16&maxiter=100",
+		golden: []int{
+			0, 0, 0, 1, 1, 1, 1, 1, 1, 2, 2, 2, 1, 1, 1, 1,
+			0, 0, 1, 1, 1, 1, 1, 2, 2, 2, 3, 3, 2, 1, 1, 1,
+			0, 0, 1, 1, 1, 2, 2, 2, 2, 3, 4, 4, 2, 2, 1, 1,
+			0, 0, 1, 2, 2, 2, 2, 3, 4, 6, 100, 11, 3, 2, 1, 1,
+			0, 1, 2, 2, 3, 5, 5, 6, 9, 100, 100, 100, 3, 2, 1, 1,
+			0, 100, 100, 100, 100, 100, 100, 100, 100, 100, 100, 7, 3, 2, 1, 1,
+			0, 2, 3, 6, 100, 100, 100, 100, 100, 100, 100, 7, 3, 2, 1, 1,
+			0, 0, 2, 3, 34, 100, 100, 100, 100, 100, 100, 10, 3, 2, 1, 1,
+			0, 0, 2, 2, 2, 12, 100, 100, 100, 100, 100, 100, 4, 2, 2, 1,
+			0, 0, 1, 2, 2, 25, 18, 100, 100, 100, 100, 100, 7, 3, 2, 1,
+			0, 0, 0, 1, 2, 4, 87, 43, 20, 100, 100, 100, 100, 5, 2, 1,
+			0, 0, 0, 1, 1, 2, 4, 2, 2, 2, 60, 4, 100, 44, 7, 1,
+			0, 0, 0, 0, 1, 1, 1, 1, 1, 1, 1, 2, 3, 14, 3, 1,
+			0, 0, 0, 0, 0, 1, 1, 1, 1, 1, 1, 1, 2, 5, 2, 0,
+			0, 0, 0, 0, 0, 0, 1, 1, 1, 1, 1, 1, 1, 1, 0, 0,
+			0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		},
+	},
+	{
+		name:  "celtic",
+		query: "fractal=celtic&width=16&height=16&maxiter=100",
+		golden: []int{
+			0, 0, 0, 0, 1, 3, 5, 3, 2, 1, 1, 1, 1, 1, 1, 1,
+			0, 0, 0, 1, 1, 2, 6, 5, 2, 2, 1, 1, 1, 1, 1, 1,
+			0, 0, 1, 1, 2, 2, 7, 100, 4, 2, 2, 1, 1, 1, 1, 1,
+			0, 0, 2, 2, 2, 2, 4, 100, 8, 3, 2, 2, 2, 1, 1, 1,
+			0, 1, 7, 5, 13, 31, 100, 100, 100, 6, 3, 2, 2, 2, 1, 1,
+			0, 2, 3, 6, 10, 5, 9, 100, 100, 18, 5, 4, 3, 2, 2, 1,
+			0, 3, 3, 6, 17, 9, 9, 100, 100, 100, 47, 7, 4, 3, 2, 1,
+			0, 7, 7, 16, 100, 100, 100, 100, 100, 100, 100, 100, 6, 3, 2, 1,
+			0, 7, 7, 16, 100, 100, 100, 100, 100, 100, 100, 100, 6, 3, 2, 1,
+			0, 3, 3, 6, 17, 9, 9, 100, 100, 100, 47, 7, 4, 3, 2, 1,
+			0, 2, 3, 6, 10, 5, 9, 100, 100, 18, 5, 4, 3, 2, 2, 1,
+			0, 1, 7, 5, 13, 31, 100, 100, 100, 6, 3, 2, 2, 2, 1, 1,
+			0, 0, 2, 2, 2, 2, 4, 100, 8, 3, 2, 2, 2, 1, 1, 1,
+			0, 0, 1, 1, 2, 2, 7, 100, 4, 2, 2, 1, 1, 1, 1, 1,
+			0, 0, 0, 1, 1, 2, 6, 5, 2, 2, 1, 1, 1, 1, 1, 1,
+			0, 0, 0, 0, 1, 3, 5, 3, 2, 1, 1, 1, 1, 1, 1, 1,
+		},
+	},
+	{
+		name:  "heart",
+		query: "fractal=heart&width=16&height=16&maxiter=100",
+		golden: []int{
+			1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 2, 1, 1, 1, 1, 1,
+			1, 1, 1, 1, 1, 1, 1, 1, 1, 2, 2, 2, 2, 1, 1, 1,
+			1, 1, 1, 1, 1, 1, 1, 1, 2, 2, 2, 2, 2, 2, 1, 1,
+			1, 1, 1, 1, 1, 2, 2, 2, 2, 2, 3, 3, 3, 3, 2, 1,
+			1, 1, 2, 2, 2, 2, 2, 2, 3, 3, 4, 5, 5, 3, 2, 2,
+			2, 2, 2, 2, 2, 2, 3, 3, 4, 5, 8, 100, 100, 4, 2, 2,
+			2, 2, 2, 3, 4, 4, 4, 5, 7, 13, 100, 100, 100, 5, 3, 2,
+			4, 4, 5, 7, 14, 100, 18, 22, 100, 100, 100, 100, 100, 4, 3, 2,
+			4, 4, 5, 7, 14, 100, 18, 22, 100, 100, 100, 100, 100, 4, 3, 2,
+			2, 2, 2, 3, 4, 4, 4, 5, 7, 13, 100, 100, 100, 5, 3, 2,
+			2, 2, 2, 2, 2, 2, 3, 3, 4, 5, 8, 100, 100, 4, 2, 2,
+			1, 1, 2, 2, 2, 2, 2, 2, 3, 3, 4, 5, 5, 3, 2, 2,
+			1, 1, 1, 1, 1, 2, 2, 2, 2, 2, 3, 3, 3, 3, 2, 1,
+			1, 1, 1, 1, 1, 1, 1, 1, 2, 2, 2, 2, 2, 2, 1, 1,
+			1, 1, 1, 1, 1, 1, 1, 1, 1, 2, 2, 2, 2, 1, 1, 1,
+			1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 2, 1, 1, 1, 1, 1,
+		},
+	},
+	{
+		name:  "multibrot",
+		query: "fractal=multibrot&power=3&width=16&height=16&maxiter=100",
+		golden: []int{
+			0, 1, 1, 1, 1, 1, 1, 2, 2, 1, 1, 1, 1, 1, 1, 0,
+			1, 1, 1, 1, 1, 2, 4, 3, 3, 4, 2, 1, 1, 1, 1, 1,
+			1, 1, 1, 1, 1, 2, 15, 100, 100, 15, 2, 1, 1, 1, 1, 1,
+			1, 1, 1, 1, 2, 3, 4, 100, 100, 4, 3, 2, 1, 1, 1, 1,
+			1, 1, 1, 1, 2, 4, 100, 100, 100, 100, 4, 2, 1, 1, 1, 1,
+			1, 1, 1, 2, 5, 100, 100, 100, 100, 100, 100, 5, 2, 1, 1, 1,
+			1, 1, 1, 2, 3, 100, 100, 100, 100, 100, 100, 3, 2, 1, 1, 1,
+			1, 1, 1, 2, 3, 100, 100, 100, 100, 100, 100, 3, 2, 1, 1, 1,
+			1, 1, 1, 2, 3, 100, 100, 100, 100, 100, 100, 3, 2, 1, 1, 1,
+			1, 1, 1, 2, 3, 100, 100, 100, 100, 100, 100, 3, 2, 1, 1, 1,
+			1, 1, 1, 2, 5, 100, 100, 100, 100, 100, 100, 5, 2, 1, 1, 1,
+			1, 1, 1, 1, 2, 4, 100, 100, 100, 100, 4, 2, 1, 1, 1, 1,
+			1, 1, 1, 1, 2, 3, 4, 100, 100, 4, 3, 2, 1, 1, 1, 1,
+			1, 1, 1, 1, 1, 2, 15, 100, 100, 15, 2, 1, 1, 1, 1, 1,
+			1, 1, 1, 1, 1, 2, 4, 3, 3, 4, 2, 1, 1, 1, 1, 1,
+			0, 1, 1, 1, 1, 1, 1, 2, 2, 1, 1, 1, 1, 1, 1, 0,
+		},
+	},
+}
+
+// selfTest computes every case and reports the mismatches.  It returns false if
+// any iteration count differs from the reference.
+func selfTest() bool {
+	ok := true
+	for _, tc := range selfTestCases {
+		q, err := url.ParseQuery(tc.query)
+		if err != nil {
+			fmt.Printf("error: self test %s: %v\n", tc.name, err)
+			ok = false
+			continue
+		}
+		p, errs := parseParams(q)
+		if len(errs) > 0 {
+			fmt.Printf("error: self test %s: %v\n", tc.name, errs[0])
+			ok = false
+			continue
+		}
+		grid := computeGrid(p)
+		if len(grid.its) != len(tc.golden) {
+			fmt.Printf("error: self test %s: %d cells, the reference has %d\n", tc.name, len(grid.its), len(tc.golden))
+			ok = false
+			continue
+		}
+		mismatches := 0
+		for i, its := range grid.its {
+			if its != tc.golden[i] {
+				if mismatches == 0 {
+					fmt.Printf("error: self test %s: cell (%d,%d) took %d iterations, the reference is %d\n",
+						tc.name, i/p.columns, i%p.columns, its, tc.golden[i])
+				}
+				mismatches++
+			}
+		}
+		if mismatches > 0 {
+			fmt.Printf("error: self test %s: %d of %d cells differ\n", tc.name, mismatches, len(grid.its))
+			ok = false
+			continue
+		}
+		fmt.Printf("Self test %s: ok\n", tc.name)
+	}
+	return ok
+}
+
+// runSelfTest runs the self test and exits with its result
+func runSelfTest() {
+	if !selfTest() {
+		fmt.Printf("Self test failed\n")
+		os.Exit(1)
+	}
+	fmt.Printf("Self test passed\n")
+	os.Exit(0)
+}
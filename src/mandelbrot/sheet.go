@@ -0,0 +1,116 @@
+// Contact sheets of many windows.  A JSON array of plot parameters, typically
+// just the windows, is POSTed to /mandelbrot/sheet and the plots are returned as
+// thumbnails in one PNG.  The query parameters are shared by all the thumbnails.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+const (
+	patternSheet = "/mandelbrot/sheet" // http handler pattern for contact sheets
+	maxSheet     = 64                  // largest number of windows on a sheet
+	thumbSize    = 150                 // default thumbnail width and height
+	sheetGap     = 4                   // pixels between the thumbnails
+)
+
+// forms looks up a parameter in each form in turn, the first nonempty value wins
+type forms []Form
+
+func (fs forms) Get(name string) string {
+	for _, f := range fs {
+		if v := f.Get(name); len(v) > 0 {
+			return v
+		}
+	}
+	return ""
+}
+
+// handleSheet renders every window of the POSTed array as a thumbnail and sends
+// the contact sheet as a PNG.  The thumbnails are laid out in rows of cols, the
+// square root of the number of windows by default.
+func handleSheet(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, &ParamError{Code: ErrMethod, Message: "contact sheets are requested with POST"})
+		return
+	}
+	var windows []PlotRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&windows); err != nil {
+		writeError(w, http.StatusBadRequest, &ParamError{Code: ErrBadRequest, Message: fmt.Sprintf("JSON body: %v", err)})
+		return
+	}
+	if len(windows) < 1 || len(windows) > maxSheet {
+		writeError(w, http.StatusBadRequest, &ParamError{Code: ErrOutOfRange,
+			Message: fmt.Sprintf("the sheet must have from 1 to %d windows", maxSheet)})
+		return
+	}
+
+	query := r.URL.Query()
+	cols := int(math.Ceil(math.Sqrt(float64(len(windows)))))
+	if cs := query.Get("cols"); len(cs) > 0 {
+		n, err := strconv.Atoi(cs)
+		if err != nil || n < 1 || n > maxSheet {
+			writeError(w, http.StatusBadRequest, &ParamError{Code: numberCode(err),
+				Message: fmt.Sprintf("cols must be an integer from 1 to %d", maxSheet), Param: "cols"})
+			return
+		}
+		cols = n
+	}
+	thumbDefault := url.Values{}
+	thumbDefault.Set("width", strconv.Itoa(thumbSize))
+	thumbDefault.Set("height", strconv.Itoa(thumbSize))
+
+	// The window parameters override the query, which overrides the thumbnail size
+	thumbs := make([]*image.RGBA, len(windows))
+	cellw, cellh := 0, 0
+	for i := range windows {
+		p, errs := parseParams(forms{&windows[i], query, thumbDefault})
+		if len(errs) > 0 {
+			e := *errs[0]
+			e.Message = fmt.Sprintf("window %d: %s", i, e.Message)
+			writeError(w, http.StatusBadRequest, &e)
+			return
+		}
+		if limit := float64(maxSize*p.ssaa) * p.dpr; float64(p.rows) > limit || float64(p.columns) > limit {
+			writeError(w, http.StatusBadRequest, &ParamError{Code: ErrTooLarge,
+				Message: fmt.Sprintf("window %d is larger than %d x %d", i, maxSize, maxSize)})
+			return
+		}
+		thumbs[i] = pngImage(renderGrid(p))
+		if dx := thumbs[i].Rect.Dx(); dx > cellw {
+			cellw = dx
+		}
+		if dy := thumbs[i].Rect.Dy(); dy > cellh {
+			cellh = dy
+		}
+	}
+
+	if cols > len(thumbs) {
+		cols = len(thumbs)
+	}
+	rows := (len(thumbs) + cols - 1) / cols
+	sheet := image.NewRGBA(image.Rect(0, 0, cols*cellw+(cols+1)*sheetGap, rows*cellh+(rows+1)*sheetGap))
+	draw.Draw(sheet, sheet.Rect, image.White, image.Point{}, draw.Src)
+	for i, thumb := range thumbs {
+		at := image.Pt(sheetGap+(i%cols)*(cellw+sheetGap), sheetGap+(i/cols)*(cellh+sheetGap))
+		draw.Draw(sheet, thumb.Rect.Add(at), thumb, image.Point{}, draw.Src)
+	}
+	fmt.Printf("Contact sheet: %d windows in %d x %d\n", len(thumbs), cols, rows)
+
+	w.Header().Set("Content-Type", "image/png")
+	if err := png.Encode(w, sheet); err != nil {
+		fmt.Printf("error: write contact sheet: %v\n", err)
+	}
+}
@@ -0,0 +1,143 @@
+// Slow escape detection for slowescape=flag or recheck.  A cell that reaches the
+// iteration cap is either a member of the set, whose orbit settles into an
+// attracting cycle, or a slowly escaping cell near the boundary that maxiter was
+// too small to resolve.  The orbits of the cells at the cap are iterated again
+// with cycle detection: a cell whose orbit returns to within periodEpsilon of an
+// earlier point is a member, the others are slow.  With recheck the slow cells
+// are iterated on to recheckiter and those that escape get their iterations, so
+// the higher cap is only paid at the boundary.  The slow cells left are colored
+// in slowColor, apart from the members.
+
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math/cmplx"
+	"runtime/debug"
+	"sync"
+)
+
+const periodEpsilon = 1e-12 // distance of an orbit point from an earlier one that is a cycle
+
+var slowColor = color.RGBA{0xff, 0x80, 0x00, 0xff} // cells at the cap without a cycle, orange
+
+// SlowEscape is the outcome of the slow escape pass of a grid
+type SlowEscape struct {
+	cells   []bool // the cell reached the cap without a cycle, nil in a downscaled grid
+	capped  int    // cells at maxiter before the pass
+	extra   int    // capped cells without a cycle by maxiter, which took the extra pass
+	escaped int    // extra cells that escaped by recheckiter
+}
+
+// String is the report of the pass in the X-Slow-Escape header
+func (s *SlowEscape) String() string {
+	return fmt.Sprintf("%d of %d cells at maxiter had no cycle, %d of them escaped", s.extra, s.capped, s.escaped)
+}
+
+// slowGrid computes the grid at maxiter and classifies the cells that reached it
+// by their cycles, iterating on the slow ones to the iteration cap of the plot
+func slowGrid(p *Params) *Grid {
+	base := *p
+	base.slowesc = ""
+	base.iterations = iterationCap(p.maxiter, p.radius, p.degree())
+	grid := computeGrid(&base)
+	grid.p = p
+	slow := &SlowEscape{cells: make([]bool, len(grid.its))}
+	it := fractals[p.fractal].iterator(p)
+
+	// Rows of cells are classified concurrently, each row counting its own cells.
+	// A row that panics is failed as by recoverRow.
+	counts := make([][3]int, p.rows)
+	failed := make([]bool, p.rows)
+	var wg sync.WaitGroup
+	for row := 0; row < p.rows; row++ {
+		wg.Add(1)
+		go func(row int) {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					fmt.Printf("error: slow escape row %d panicked: %v\n%s", row, r, debug.Stack())
+					for col := 0; col < p.columns; col++ {
+						grid.its[row*p.columns+col] = failedIts
+					}
+					failed[row] = true
+				}
+			}()
+			for col := 0; col < p.columns; col++ {
+				i := row*p.columns + col
+				if grid.its[i] != base.iterations {
+					continue
+				}
+				counts[row][0]++
+				point := cellPoint(row, col, p)
+				c := point
+				if p.julia {
+					c = p.c
+				}
+				if p.bulbs && inMainBulbs(c) {
+					grid.its[i] = p.iterations
+					continue
+				}
+				n, cycle := slowOrbit(point, p, it)
+				switch {
+				case cycle && n < base.iterations:
+					grid.its[i] = p.iterations
+					continue
+				case cycle || n == p.iterations:
+					grid.its[i] = p.iterations
+					slow.cells[i] = !cycle
+				default:
+					grid.its[i] = n
+					counts[row][2]++
+				}
+				counts[row][1]++
+			}
+		}(row)
+	}
+	wg.Wait()
+
+	for row, n := range counts {
+		slow.capped += n[0]
+		slow.extra += n[1]
+		slow.escaped += n[2]
+		grid.failed = grid.failed || failed[row]
+	}
+	grid.maxits = 0
+	for _, its := range grid.its {
+		if its > grid.maxits {
+			grid.maxits = its
+		}
+	}
+	grid.slow = slow
+	fmt.Printf("Slow escape: %s\n", slow)
+	return grid
+}
+
+// slowOrbit iterates the orbit of the cell point to the iteration cap and returns
+// the iteration it escaped or returned to an earlier point at, and true for the
+// cycle.  The earlier point is moved to the orbit at every power of two, so cycles
+// up to half the iterations so far are found.
+func slowOrbit(point complex128, p *Params, it Iterator) (int, bool) {
+	v, c := p.z0, point
+	if p.zstartc {
+		v = point
+	}
+	if p.julia {
+		v, c = point, p.c
+	}
+	earlier, check := v, 1
+	for n := 0; n < p.iterations; n++ {
+		v = it.Next(v, c)
+		if it.Escaped(v) {
+			return n, false
+		}
+		if cmplx.Abs(v-earlier) < periodEpsilon {
+			return n, true
+		}
+		if n == check {
+			earlier, check = v, 2*check
+		}
+	}
+	return p.iterations, false
+}
@@ -0,0 +1,111 @@
+// Streaming PNG encoding.  With -streampng the PNG plots are computed a band of
+// rows at a time as the encoder reads them, so the memory is bounded by a few
+// rows instead of the whole grid and its colors.  The grid cache is bypassed.
+
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"time"
+)
+
+const streamBand = 16 // pixel rows computed at a time
+
+// rowImage is the plot as an image.Image that computes its pixels on demand, the
+// band of rows holding the pixel at a time.  png.Encode reads the pixels in
+// order, so every band is computed once.
+type rowImage struct {
+	p    *Params
+	it   Iterator
+	top  int         // first pixel row of the band
+	band *image.RGBA // colored band, nil before the first
+
+	computeMs float64   // compute and coloring time of the bands so far
+	rowms     []float64 // compute milliseconds of each grid row, as for a Grid
+}
+
+// streamable is true if the plot can be colored one band at a time:  the
+// coloring is per cell and the plot has no legend, pooling, palette, difference,
+// overlay, mask, tint, axes or inset to build from the whole grid, and is not
+// refined adaptively
+func (p *Params) streamable() bool {
+	return p.coloring != "potential" && p.coloring != "edge" && p.coloring != "firstentry" && p.coloring != "components" && !p.legend && !p.annotate && p.downscale == 1 && !p.paletted &&
+		p.diffmax == 0 && len(p.overlay) == 0 && !p.circle && p.vignette == 0 && p.bgtint == 0 && !p.adaptive && !p.axes &&
+		p.insetwin == (Endpoints{}) && p.workaxis == "row" &&
+		len(p.slowesc) == 0
+}
+
+// writeStream encodes the plot as a PNG, computing the rows as they are encoded
+func writeStream(w io.Writer, p *Params) error {
+	return newRowImage(p).write(w, nil)
+}
+
+func newRowImage(p *Params) *rowImage {
+	return &rowImage{p: p, it: fractals[p.fractal].iterator(p), rowms: make([]float64, p.rows)}
+}
+
+// write is the Encoder write of the streamed plot, which has no grid
+func (img *rowImage) write(w io.Writer, _ *Grid) error {
+	return png.Encode(w, img)
+}
+
+func (img *rowImage) ColorModel() color.Model { return color.RGBAModel }
+
+func (img *rowImage) Bounds() image.Rectangle {
+	return image.Rect(0, 0, img.p.columns/img.p.ssaa, img.p.rows/img.p.ssaa)
+}
+
+// Opaque tells png.Encode whether the image has transparency, so it does not read
+// every pixel to look for it, which would compute the bands twice.  With the
+// members of the set transparent the image is encoded with its alpha.
+func (img *rowImage) Opaque() bool { return !img.p.clearset }
+
+func (img *rowImage) At(x, y int) color.Color {
+	if img.band == nil || y < img.top || y >= img.top+img.band.Rect.Dy() {
+		img.compute(y - y%streamBand)
+	}
+	return img.band.RGBAAt(x, y-img.top)
+}
+
+// compute colors the band of pixel rows from top with plotImage.  The grid rows
+// of the band are computed concurrently by processRow.  Without the whole grid
+// the default color range is the full iteration range, as for the map tiles.
+func (img *rowImage) compute(top int) {
+	begin := time.Now()
+	p := img.p
+	n := p.ssaa
+	first := top * n
+	last := first + streamBand*n
+	if last > p.rows {
+		last = p.rows
+	}
+
+	band := *p
+	band.rows = last - first
+	grid := Grid{p: &band, its: make([]int, band.rows*p.columns), maxits: p.iterations}
+	if p.keepOrbit() {
+		grid.z = make([]complex128, len(grid.its))
+		grid.dz = make([]complex128, len(grid.its))
+	}
+	result := make(chan Result)
+	for row := first; row < last; row++ {
+		go processRow(row, result, p, img.it)
+	}
+	for row := first; row < last; row++ {
+		res := <-result
+		k := (res.row - first) * p.columns
+		copy(grid.its[k:], res.its)
+		img.rowms[res.row] = res.ms
+		grid.failed = grid.failed || res.failed
+		if grid.z != nil {
+			copy(grid.z[k:], res.z)
+			copy(grid.dz[k:], res.dz)
+		}
+	}
+	img.top = top
+	img.band = plotImage(&grid)
+	img.computeMs += ms(time.Since(begin))
+}
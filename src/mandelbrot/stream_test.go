@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"image/png"
+	"sync/atomic"
+	"testing"
+)
+
+// countingIterator counts the escape tests of the iterator
+type countingIterator struct {
+	Iterator
+	tests *int64
+}
+
+func (c countingIterator) Escaped(z complex128) bool {
+	atomic.AddInt64(c.tests, 1)
+	return c.Iterator.Escaped(z)
+}
+
+// The streamed plot computes every band once, also with a transparent set, whose
+// image png.Encode takes to have transparency from Opaque without reading it
+func TestStreamBandsOnce(t *testing.T) {
+	const query = "width=60&height=50&colormin=0&colormax=200"
+	var tests [2]int64
+	for i, clear := range []string{"false", "true"} {
+		p := testParams(t, query+"&transparentset="+clear)
+		img := newRowImage(p)
+		img.it = countingIterator{img.it, &tests[i]}
+		var buf bytes.Buffer
+		if err := img.write(&buf, nil); err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := png.Decode(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The middle of the default window is in the set
+		if _, _, _, a := decoded.At(30, 25).RGBA(); (a == 0) != p.clearset {
+			t.Errorf("transparentset=%s: the set has alpha %d", clear, a)
+		}
+	}
+	if tests[0] != tests[1] {
+		t.Errorf("%d escape tests with a transparent set, %d without", tests[1], tests[0])
+	}
+}
@@ -0,0 +1,109 @@
+// Stress test endpoint for generating load on the compute core.  It is only
+// registered when the server is started with -enable-stress.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	patternStress = "/mandelbrot/stress" // http handler pattern for the stress test
+	maxStress     = 1000                 // maximum number of renders in one stress test
+)
+
+// StressStats are the aggregate render durations of a stress test
+type StressStats struct {
+	Renders int     `json:"renders"`
+	Total   float64 `json:"total_ms"` // wall clock time of the whole test
+	Min     float64 `json:"min_ms"`
+	Max     float64 `json:"max_ms"`
+	Avg     float64 `json:"avg_ms"`
+	P99     float64 `json:"p99_ms"`
+}
+
+// handleStress runs n concurrent renders of the requested plot, bypassing the
+// cache, and reports the timing statistics as JSON.
+func handleStress(w http.ResponseWriter, r *http.Request) {
+	n := 10
+	if ns := r.FormValue("n"); len(ns) > 0 {
+		v, err := strconv.Atoi(ns)
+		if err != nil || v < 1 || v > maxStress {
+			writeError(w, http.StatusBadRequest, &ParamError{Code: numberCode(err),
+				Message: fmt.Sprintf("n must be an integer from 1 to %d", maxStress), Param: "n"})
+			return
+		}
+		n = v
+	}
+
+	form, err := requestForm(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, &ParamError{Code: ErrBadRequest, Message: fmt.Sprintf("request parameters: %v", err)})
+		return
+	}
+	p, errs := parseParams(form)
+	if len(errs) > 0 {
+		writeErrors(w, http.StatusBadRequest, errs)
+		return
+	}
+	if limit := float64(maxSize*p.ssaa) * p.dpr; float64(p.rows) > limit || float64(p.columns) > limit {
+		writeError(w, http.StatusBadRequest, &ParamError{Code: ErrTooLarge,
+			Message: fmt.Sprintf("stress tests of plots larger than %d x %d are not run", maxSize, maxSize)})
+		return
+	}
+	durations := make([]time.Duration, n)
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			begin := time.Now()
+			computeGrid(p)
+			durations[i] = time.Since(begin)
+		}(i)
+	}
+	wg.Wait()
+
+	stats := stressStats(durations)
+	stats.Total = ms(time.Since(start))
+	fmt.Printf("Stress test: %d renders in %.1f ms\n", n, stats.Total)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(stats); err != nil {
+		fmt.Printf("error: write stress statistics: %v\n", err)
+	}
+}
+
+// stressStats computes the min, max, average and 99th percentile durations
+func stressStats(durations []time.Duration) StressStats {
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+
+	var sum time.Duration
+	for _, d := range durations {
+		sum += d
+	}
+	// nearest rank percentile
+	p99 := int(math.Ceil(.99*float64(len(durations)))) - 1
+
+	return StressStats{
+		Renders: len(durations),
+		Min:     ms(durations[0]),
+		Max:     ms(durations[len(durations)-1]),
+		Avg:     ms(sum / time.Duration(len(durations))),
+		P99:     ms(durations[p99]),
+	}
+}
+
+// ms converts the duration to milliseconds
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,118 @@
+// Slippy map tile server.  The fractal's default window is the world at zoom 0
+// and each zoom level splits every tile into four, so map clients such as
+// Leaflet can pan and zoom with the URL template
+// /mandelbrot/tile?z={z}&x={x}&y={y}.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+const (
+	patternTile = "/mandelbrot/tile" // http handler pattern for map tiles
+	tileSize    = 256                // tile width and height in pixels
+	maxTileZoom = 44                 // deepest zoom before float64 runs out of precision
+)
+
+// TileKey identifies an encoded tile in the tile cache.  The tile endpoint only
+// accepts these parameters so the key covers everything that changes the tile.
+type TileKey struct {
+	z, x, y int
+	maxiter int
+	palette string
+	fractal string
+}
+
+var (
+	tiles         *LRU[TileKey, []byte]          // encoded PNG tiles
+	tilesInFlight = newFlight[TileKey, []byte]() // tiles being rendered
+)
+
+// tileEndpoints is the window of tile (x,y) at zoom z.  The world is the square
+// around the fractal's default window, x increases to the right and y downward.
+func tileEndpoints(def Endpoints, z, x, y int) Endpoints {
+	side := math.Max(def.xmax-def.xmin, def.ymax-def.ymin)
+	xc := (def.xmin + def.xmax) / 2
+	yc := (def.ymin + def.ymax) / 2
+	size := side / float64(int64(1)<<uint(z))
+
+	xmin := xc - side/2 + float64(x)*size
+	ymax := yc + side/2 - float64(y)*size
+	return Endpoints{xmin, xmin + size, ymax - size, ymax}
+}
+
+// handleTile serves the PNG map tile, from the tile cache when it was rendered before
+func handleTile(w http.ResponseWriter, r *http.Request) {
+	var coord [3]int // z, x, y
+	for i, name := range []string{"z", "x", "y"} {
+		v, err := strconv.Atoi(r.FormValue(name))
+		if err != nil || v < 0 {
+			writeError(w, http.StatusBadRequest, &ParamError{Code: numberCode(err),
+				Message: fmt.Sprintf("tile %s is not a nonnegative integer", name), Param: name})
+			return
+		}
+		coord[i] = v
+	}
+	z, x, y := coord[0], coord[1], coord[2]
+	if z > maxTileZoom || x >= 1<<uint(z) || y >= 1<<uint(z) {
+		writeError(w, http.StatusBadRequest, &ParamError{Code: ErrOutOfRange,
+			Message: fmt.Sprintf("tile (%d,%d) is not at zoom %d from 0 to %d", x, y, z, maxTileZoom)})
+		return
+	}
+
+	// Resolve the tile's plot parameters from only the parameters in the key
+	q := url.Values{}
+	for _, name := range []string{"fractal", "maxiter", "palette"} {
+		if v := r.FormValue(name); len(v) > 0 {
+			q.Set(name, v)
+		}
+	}
+	p, errs := parseParams(q)
+	if len(errs) > 0 {
+		writeErrors(w, http.StatusBadRequest, errs)
+		return
+	}
+	key := TileKey{z, x, y, p.maxiter, p.palette, p.fractal}
+
+	w.Header().Set("Content-Type", "image/png")
+	if png, ok := tiles.Get(key); ok {
+		w.Header().Set("X-Cache", "HIT")
+		w.Write(png)
+		return
+	}
+
+	// A tile requested again while it renders, as by a prefetch and a pan, is
+	// rendered once for both requests
+	png, err, shared := tilesInFlight.Do(key, func() ([]byte, error) {
+		p.rows, p.columns, p.ssaa = tileSize, tileSize, 1
+		p.ep = tileEndpoints(fractals[p.fractal].endpoints, z, x, y)
+		// Color every tile over the full iteration range so neighboring tiles match
+		p.colormin, p.colormax = 0, p.iterations
+		grid := computeGrid(p)
+		var buf bytes.Buffer
+		if err := writePNG(&buf, grid); err != nil {
+			return nil, err
+		}
+		// A tile with failed rows is rendered again by the next request
+		if !grid.failed {
+			tiles.Put(key, buf.Bytes())
+		}
+		return buf.Bytes(), nil
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if shared {
+		w.Header().Set("X-Cache", "SHARED")
+	} else {
+		w.Header().Set("X-Cache", "MISS")
+	}
+	w.Write(png)
+}
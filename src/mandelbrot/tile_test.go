@@ -0,0 +1,27 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+// A repeated tile is served from the tile cache with the bytes of the first
+// render, and a tile of other parameters is rendered
+func TestTileCache(t *testing.T) {
+	const target = patternTile + "?z=3&x=2&y=5&maxiter=123"
+	first := serve(handleTile, target)
+	if first.Code != 200 || first.Header().Get("X-Cache") != "MISS" {
+		t.Fatalf("first request: status %d, X-Cache %q", first.Code, first.Header().Get("X-Cache"))
+	}
+	again := serve(handleTile, target)
+	if again.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("repeated request: X-Cache %q, want HIT", again.Header().Get("X-Cache"))
+	}
+	if !bytes.Equal(again.Body.Bytes(), first.Body.Bytes()) {
+		t.Errorf("the cached tile differs from the rendered one")
+	}
+	other := serve(handleTile, patternTile+"?z=3&x=2&y=5&maxiter=124")
+	if other.Header().Get("X-Cache") != "MISS" {
+		t.Errorf("tile of another maxiter: X-Cache %q, want MISS", other.Header().Get("X-Cache"))
+	}
+}
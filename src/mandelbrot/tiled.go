@@ -0,0 +1,75 @@
+// Tiled distributed rendering.  With fullwidth and fullheight the plot is the tile
+// of tilewidth x tileheight pixels at offsetx, offsety of a full image of that size
+// over the window, so a large render can be split across machines and the tiles
+// assembled without seams.  The cells are mapped to the plane by their position in
+// the full image, so a tile has exactly the cells of the full image in its place,
+// and the color range is from 0 to the iteration cap unless colormin or colormax
+// are given, so the tiles share their colors.
+
+package main
+
+import "strconv"
+
+const maxFullSize = 1 << 20 // largest fullwidth or fullheight
+
+// parseTile reads the tile of the full image from the form.  The features that
+// depend on the whole image, the mask, inset, legend, annotation, downscale and
+// selection and the colorings of the neighbor cells, do not apply to a tile, nor
+// does fixed point, which steps over the cells of the plot by its own arithmetic.
+func parseTile(form Form, p *Params, errs *ParamErrors) {
+	names := [6]string{"fullwidth", "fullheight", "tilewidth", "tileheight", "offsetx", "offsety"}
+	var v [6]int
+	given := 0
+	for i, name := range names {
+		s := form.Get(name)
+		if len(s) == 0 {
+			continue
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 || n > maxFullSize {
+			errs.add(numberCode(err), name, "%s %q is not an integer from 0 to %d.", name, s, maxFullSize)
+			return
+		}
+		v[i] = n
+		given++
+	}
+	if given == 0 {
+		return
+	}
+	fullw, fullh, tilew, tileh, offx, offy := v[0], v[1], v[2], v[3], v[4], v[5]
+	switch {
+	case fullw == 0 || fullh == 0 || tilew == 0 || tileh == 0:
+		errs.add(ErrOutOfRange, "fullwidth", "fullwidth, fullheight, tilewidth and tileheight are given together and are at least 1.")
+	case offx+tilew > fullw || offy+tileh > fullh:
+		errs.add(ErrOutOfRange, "offsetx", "the tile at offsetx, offsety is not within the full image.")
+	case len(form.Get("width")) > 0 || len(form.Get("height")) > 0 || p.dpr != 1:
+		errs.add(ErrNotApplicable, "width", "width, height and dpr do not apply to a tile, its size is tilewidth x tileheight.")
+	case p.circle || p.vignette > 0 || p.insetwin != (Endpoints{}) || p.legend || p.annotate || p.downscale > 1 ||
+		len(form.Get("selx1")) > 0:
+		errs.add(ErrNotApplicable, "fullwidth", "a tile does not apply with mask, vignette, inset, legend, annotate, downscale or a selection.")
+	case p.coloring == "edge" || p.coloring == "components":
+		errs.add(ErrNotApplicable, "fullwidth", "a tile does not apply to the %s coloring.", p.coloring)
+	case p.fixed:
+		errs.add(ErrNotApplicable, "fullwidth", "a tile does not apply with fixedpoint.")
+	default:
+		p.columns, p.rows = tilew, tileh
+		p.fullw, p.fullh, p.offx, p.offy = fullw, fullh, offx, offy
+		if p.colormin < 0 {
+			p.colormin = 0
+		}
+		if p.colormax < 0 {
+			p.colormax = p.iterations
+		}
+		// The reflected cells of a tile are in other tiles
+		p.mirror = false
+	}
+}
+
+// fullSize is the columns and rows of the full image of a tile, of the plot
+// otherwise
+func (p *Params) fullSize() (int, int) {
+	if p.fullw == 0 {
+		return p.columns, p.rows
+	}
+	return p.fullw, p.fullh
+}
@@ -0,0 +1,56 @@
+// Timing statistics of a plot.  With timing=true the plot endpoint reports where
+// the time of the request went in a Server-Timing trailer, sent after the body so
+// that it covers the encoding and the rows computed while streaming, and JSON
+// plots also carry the compute time of every row in their timing field.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Timing is the compute time of a plot
+type Timing struct {
+	ComputeMs float64   `json:"compute_ms"` // time to compute the grid, or to fetch it from the cache
+	Cached    bool      `json:"cached"`     // the grid came from the cache, rows_ms are of its first compute
+	RowsMs    []float64 `json:"rows_ms"`    // compute time of each grid row, null if not computed by rows
+}
+
+// writeTimedJSON is writeJSON with the timing field
+func writeTimedJSON(t *Timing) func(io.Writer, *Grid) error {
+	return func(w io.Writer, grid *Grid) error {
+		plot := plotJSON(grid)
+		plot.Timing = t
+		return json.NewEncoder(w).Encode(plot)
+	}
+}
+
+// serverTiming is the Server-Timing value of the timing and the encode and total
+// times of the request.  The rows metric is the slowest row, described with its
+// number and the mean row time.
+func serverTiming(t Timing, encode, total time.Duration) string {
+	metrics := []string{
+		fmt.Sprintf("compute;dur=%.3f", t.ComputeMs),
+		fmt.Sprintf("encode;dur=%.3f", ms(encode)),
+		fmt.Sprintf("total;dur=%.3f", ms(total)),
+	}
+	if t.Cached {
+		metrics = append(metrics, `cache;desc="hit"`)
+	}
+	if len(t.RowsMs) > 0 {
+		slowest, sum := 0, 0.0
+		for i, ms := range t.RowsMs {
+			if ms > t.RowsMs[slowest] {
+				slowest = i
+			}
+			sum += ms
+		}
+		metrics = append(metrics, fmt.Sprintf(`rows;dur=%.3f;desc="slowest row %d of %d, mean %.3f ms"`,
+			t.RowsMs[slowest], slowest, len(t.RowsMs), sum/float64(len(t.RowsMs))))
+	}
+	return strings.Join(metrics, ", ")
+}
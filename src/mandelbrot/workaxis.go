@@ -0,0 +1,81 @@
+// Column work distribution for workaxis=column.  The grid is computed with a
+// goroutine per column instead of per row, to compare the load balance and the
+// cache behavior of the two decompositions.  The interior of the set spans runs
+// of rows, so the columns through it are the slow ones instead.
+
+package main
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// columnGrid computes the grid with a goroutine per column.  The results of the
+// columns carry the column in their row field and their cells from the top.
+func columnGrid(p *Params) *Grid {
+	grid := Grid{p: p, minits: p.iterations}
+	grid.its = make([]int, p.rows*p.columns)
+	if p.keepOrbit() {
+		grid.z = make([]complex128, p.rows*p.columns)
+		grid.dz = make([]complex128, p.rows*p.columns)
+	}
+	it := fractals[p.fractal].iterator(p)
+
+	result := make(chan Result)
+	for col := 0; col < p.columns; col++ {
+		go processColumn(col, result, p, it)
+	}
+	for col := 0; col < p.columns; col++ {
+		result := <-result
+		if result.minits < grid.minits {
+			grid.minits = result.minits
+		}
+		if result.maxits > grid.maxits {
+			grid.maxits = result.maxits
+		}
+		for row, its := range result.its {
+			i := row*p.columns + result.row
+			grid.its[i] = its
+			if grid.z != nil {
+				grid.z[i], grid.dz[i] = result.z[row], result.dz[row]
+			}
+		}
+		grid.failed = grid.failed || result.failed
+	}
+	return &grid
+}
+
+// processColumn determines which cells in the column are in the fractal set
+func processColumn(col int, result chan<- Result, p *Params, it Iterator) {
+	defer recoverColumn(col, result, p)
+	res := Result{row: col, its: make([]int, p.rows), minits: p.iterations}
+	if p.keepOrbit() {
+		res.z = make([]complex128, p.rows)
+		res.dz = make([]complex128, p.rows)
+	}
+
+	for row := 0; row < p.rows; row++ {
+		its, z, dz := determineSet(row, col, p, it)
+		if res.z != nil {
+			res.z[row], res.dz[row] = z, dz
+		}
+		if its > res.maxits {
+			res.maxits = its
+		}
+		if its < res.minits {
+			res.minits = its
+		}
+		res.its[row] = its
+	}
+	result <- res
+}
+
+// recoverColumn is recoverRow for the column workers
+func recoverColumn(col int, result chan<- Result, p *Params) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	fmt.Printf("error: column %d panicked: %v\n%s", col, r, debug.Stack())
+	result <- failedResult(col, p.rows, p)
+}